@@ -3,6 +3,7 @@ package wdapi
 import (
 	"fmt"
 	"net/http"
+	"sort"
 )
 
 type Alliances struct {
@@ -23,3 +24,130 @@ func (w WDAPI) GetAlliances() (*Alliances, error) {
 	}
 	return &ret, nil
 }
+
+type Alliance struct {
+	Name  string
+	Teams []string
+}
+
+// AllianceIndex provides team to alliance and alliance to teams lookups
+type AllianceIndex struct {
+	alliances map[string][]string
+	teams     map[string]string
+}
+
+// Index builds an AllianceIndex. A nil Alliances gives an empty index
+func (a *Alliances) Index() *AllianceIndex {
+	idx := &AllianceIndex{
+		alliances: make(map[string][]string),
+		teams:     make(map[string]string),
+	}
+	if a == nil {
+		return idx
+	}
+	for _, v := range a.Alliances {
+		for name, teams := range v {
+			for _, t := range teams {
+				if _, ok := idx.teams[t]; ok {
+					continue
+				}
+				idx.teams[t] = name
+				idx.alliances[name] = append(idx.alliances[name], t)
+			}
+		}
+	}
+	for _, v := range idx.alliances {
+		sort.Strings(v)
+	}
+	return idx
+}
+
+// List returns all alliances sorted by name
+func (a *Alliances) List() []Alliance {
+	return a.Index().Alliances()
+}
+
+// AllianceOf returns the alliance of a team
+func (i *AllianceIndex) AllianceOf(team string) (string, bool) {
+	v, ok := i.teams[team]
+	return v, ok
+}
+
+// TeamsOf returns the teams of an alliance in alphabetical order
+func (i *AllianceIndex) TeamsOf(alliance string) []string {
+	return append([]string{}, i.alliances[alliance]...)
+}
+
+// Alliances returns all alliances sorted by name
+func (i *AllianceIndex) Alliances() []Alliance {
+	ret := make([]Alliance, 0, len(i.alliances))
+	for name, teams := range i.alliances {
+		ret = append(ret, Alliance{Name: name, Teams: append([]string{}, teams...)})
+	}
+	sort.Slice(ret, func(a, b int) bool { return ret[a].Name < ret[b].Name })
+	return ret
+}
+
+type AllianceMismatch struct {
+	Team     string
+	Index    string
+	Metadata string
+}
+
+// CrossCheck compares the index with TeamMetadata.Alliance and returns every team where they disagree.
+// A team missing from the index has an empty Index
+func (i *AllianceIndex) CrossCheck(meta map[string]TeamMetadata) []AllianceMismatch {
+	ret := []AllianceMismatch{}
+	for name, v := range meta {
+		if v.TeamName != "" {
+			name = v.TeamName
+		}
+		if idx := i.teams[name]; idx != v.Alliance {
+			ret = append(ret, AllianceMismatch{Team: name, Index: idx, Metadata: v.Alliance})
+		}
+	}
+	sort.Slice(ret, func(a, b int) bool { return ret[a].Team < ret[b].Team })
+	return ret
+}
+
+type AllianceStats struct {
+	Name        string
+	Teams       int
+	Castles     int
+	TotalKills  int
+	CombinedElo int
+}
+
+// Stats aggregates castles held, monthly kills and elo per alliance. castles, kills and teams are optional
+func (i *AllianceIndex) Stats(castles *CastlesMacro, kills map[string]TeamKills, teams *TeamsMacro) []AllianceStats {
+	stats := make(map[string]*AllianceStats)
+	for name, members := range i.alliances {
+		stats[name] = &AllianceStats{Name: name, Teams: len(members)}
+	}
+	if castles != nil {
+		for _, c := range castles.Castles {
+			if a, ok := i.teams[c.OwnerTeam]; ok {
+				stats[a].Castles++
+			}
+		}
+	}
+	for team, v := range kills {
+		if a, ok := i.teams[team]; ok {
+			stats[a].TotalKills += v.TotalKills
+		}
+	}
+	if teams != nil {
+		for team, v := range teams.Teams {
+			if a, ok := i.teams[team]; ok {
+				stats[a].CombinedElo += v.Elo
+			}
+		}
+	}
+
+	ret := make([]AllianceStats, 0, len(stats))
+	for _, v := range stats {
+		ret = append(ret, *v)
+	}
+	sort.Slice(ret, func(a, b int) bool { return ret[a].Name < ret[b].Name })
+	return ret
+}
@@ -0,0 +1,223 @@
+package wdapi
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// AttackerWon reports whether the attack succeeded.
+// An attack is won if all defending troops were lost or the castle was fully destroyed.
+// An undefended castle (no initial defending troops) therefore always counts as won
+func (r Report) AttackerWon() bool {
+	return r.Defender.Troops.Lost >= r.Defender.Troops.Initial || r.PercentDestroyed >= 100
+}
+
+// BattleCounts are the totals of a group of battles seen from one side
+type BattleCounts struct {
+	Battles          int
+	Wins             int
+	Killed           int
+	Lost             int
+	Glory            float64
+	PercentDestroyed float64
+}
+
+func (b *BattleCounts) add(won bool, killed, lost int, glory, destroyed float64) {
+	b.Battles++
+	if won {
+		b.Wins++
+	}
+	b.Killed += killed
+	b.Lost += lost
+	b.Glory += glory
+	b.PercentDestroyed += destroyed
+}
+
+// WinRate returns the share of won battles between 0 and 1
+func (b BattleCounts) WinRate() float64 {
+	if b.Battles == 0 {
+		return 0
+	}
+	return float64(b.Wins) / float64(b.Battles)
+}
+
+// AvgPercentDestroyed returns the average PercentDestroyed over all battles
+func (b BattleCounts) AvgPercentDestroyed() float64 {
+	if b.Battles == 0 {
+		return 0
+	}
+	return b.PercentDestroyed / float64(b.Battles)
+}
+
+type PlayerBattleStats struct {
+	Name     string
+	Team     string
+	Attacks  BattleCounts
+	Defenses BattleCounts
+}
+
+// Total combines attacks and defenses
+func (p PlayerBattleStats) Total() BattleCounts {
+	return BattleCounts{
+		Battles:          p.Attacks.Battles + p.Defenses.Battles,
+		Wins:             p.Attacks.Wins + p.Defenses.Wins,
+		Killed:           p.Attacks.Killed + p.Defenses.Killed,
+		Lost:             p.Attacks.Lost + p.Defenses.Lost,
+		Glory:            p.Attacks.Glory + p.Defenses.Glory,
+		PercentDestroyed: p.Attacks.PercentDestroyed + p.Defenses.PercentDestroyed,
+	}
+}
+
+type BattleBucket struct {
+	Start time.Time
+	BattleCounts
+}
+
+// BattleSeries holds the totals of a group of battles and the same totals split into time buckets
+type BattleSeries struct {
+	BattleCounts
+	buckets map[int64]*BattleCounts
+}
+
+// Series returns the time buckets in chronological order
+func (s BattleSeries) Series() []BattleBucket {
+	ret := make([]BattleBucket, 0, len(s.buckets))
+	for k, v := range s.buckets {
+		ret = append(ret, BattleBucket{Start: time.Unix(k, 0), BattleCounts: *v})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Start.Before(ret[j].Start) })
+	return ret
+}
+
+// BattleAnalytics aggregates battle reports from the point of view of Team
+type BattleAnalytics struct {
+	Team      string
+	Bucket    time.Duration
+	Players   map[string]*PlayerBattleStats
+	Castles   map[string]*BattleSeries
+	Opponents map[string]*BattleSeries
+	seen      map[string]struct{}
+}
+
+// NewBattleAnalytics creates an empty aggregate for the given team.
+// bucket is the size of the time buckets in the castle and opponent series and defaults to one hour
+func NewBattleAnalytics(team string, bucket time.Duration) *BattleAnalytics {
+	if bucket <= 0 {
+		bucket = time.Hour
+	}
+	return &BattleAnalytics{
+		Team:      team,
+		Bucket:    bucket,
+		Players:   make(map[string]*PlayerBattleStats),
+		Castles:   make(map[string]*BattleSeries),
+		Opponents: make(map[string]*BattleSeries),
+		seen:      make(map[string]struct{}),
+	}
+}
+
+func reportKey(r Report) string {
+	return r.PlaceID.KRIDX() + "|" + r.Attacker.Name + "|" + r.Defender.Name + "|" + strconv.FormatFloat(float64(r.Timestamp), 'f', -1, 64)
+}
+
+func (b *BattleAnalytics) player(p BattlePrim) *PlayerBattleStats {
+	s, ok := b.Players[p.Name]
+	if !ok {
+		s = &PlayerBattleStats{Name: p.Name, Team: p.Team}
+		b.Players[p.Name] = s
+	}
+	return s
+}
+
+func (b *BattleAnalytics) series(m map[string]*BattleSeries, key string) *BattleSeries {
+	s, ok := m[key]
+	if !ok {
+		s = &BattleSeries{buckets: make(map[int64]*BattleCounts)}
+		m[key] = s
+	}
+	return s
+}
+
+func (s *BattleSeries) add(ts time.Time, bucket time.Duration, won bool, killed, lost int, glory, destroyed float64) {
+	s.BattleCounts.add(won, killed, lost, glory, destroyed)
+	k := ts.Truncate(bucket).Unix()
+	c, ok := s.buckets[k]
+	if !ok {
+		c = &BattleCounts{}
+		s.buckets[k] = c
+	}
+	c.add(won, killed, lost, glory, destroyed)
+}
+
+// Add aggregates reports. Reports that were already added are skipped,
+// so overlapping pages from GetBattles can be fed in directly
+func (b *BattleAnalytics) Add(reports ...Report) {
+	for _, r := range reports {
+		k := reportKey(r)
+		if _, ok := b.seen[k]; ok {
+			continue
+		}
+		b.seen[k] = struct{}{}
+
+		won := r.AttackerWon()
+		att := b.player(r.Attacker)
+		att.Attacks.add(won, r.Defender.Troops.Lost, r.Attacker.Troops.Lost, r.Attacker.GloryWon, r.PercentDestroyed)
+		def := b.player(r.Defender)
+		def.Defenses.add(!won, r.Attacker.Troops.Lost, r.Defender.Troops.Lost, r.Defender.GloryWon, r.PercentDestroyed)
+
+		// castle and opponent series are from the point of view of Team
+		var us, them BattlePrim
+		var weWon bool
+		switch b.Team {
+		case r.Attacker.Team:
+			us, them, weWon = r.Attacker, r.Defender, won
+		case r.Defender.Team:
+			us, them, weWon = r.Defender, r.Attacker, !won
+		default:
+			continue
+		}
+		ts := r.Timestamp.Time()
+		b.series(b.Castles, r.PlaceID.KRIDX()).add(ts, b.Bucket, weWon, them.Troops.Lost, us.Troops.Lost, us.GloryWon, r.PercentDestroyed)
+		b.series(b.Opponents, them.Team).add(ts, b.Bucket, weWon, them.Troops.Lost, us.Troops.Lost, us.GloryWon, r.PercentDestroyed)
+	}
+}
+
+// AddAll pages through GetBattles until there are no more reports or a report older than since is reached.
+// A zero since fetches everything the API returns
+func (b *BattleAnalytics) AddAll(w WDAPI, apikey string, since time.Time) error {
+	cursor := ""
+	for {
+		res, err := w.GetBattles(apikey, cursor)
+		if err != nil {
+			return err
+		}
+		done := !res.More
+		for _, r := range res.Reports {
+			if !since.IsZero() && r.Timestamp.Time().Before(since) {
+				done = true
+				continue
+			}
+			b.Add(r)
+		}
+		if done || res.Cursor == "" || res.Cursor == cursor {
+			return nil
+		}
+		cursor = res.Cursor
+	}
+}
+
+// Ranking returns the player stats sorted by the given key, highest first
+func (b *BattleAnalytics) Ranking(key func(PlayerBattleStats) float64) []PlayerBattleStats {
+	ret := make([]PlayerBattleStats, 0, len(b.Players))
+	for _, v := range b.Players {
+		ret = append(ret, *v)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		ki, kj := key(ret[i]), key(ret[j])
+		if ki != kj {
+			return ki > kj
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
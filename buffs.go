@@ -0,0 +1,79 @@
+package wdapi
+
+import (
+	"sort"
+	"time"
+)
+
+// Active reports whether the buff is set and not expired at now.
+// A buff expires duration after TS. A duration <= 0 or a buff without TS never expires
+func (b Buff) Active(now time.Time, duration time.Duration) bool {
+	if b.Amount == 0 {
+		return false
+	}
+	if duration <= 0 || b.TS.IsZero() {
+		return true
+	}
+	return now.Before(b.TS.Time().Add(duration))
+}
+
+// Effective returns the buff amount at now, 0 if it expired, see Active
+func (b Buff) Effective(now time.Time, duration time.Duration) int {
+	if !b.Active(now, duration) {
+		return 0
+	}
+	return b.Amount
+}
+
+// LastRefreshed returns the later of the attack and defend timestamps
+func (b Buffs) LastRefreshed() time.Time {
+	a, d := b.Attack.TS.Time(), b.Defend.TS.Time()
+	if a.After(d) {
+		return a
+	}
+	return d
+}
+
+type TeamBuff struct {
+	Team            BuffTeam
+	Attack          int
+	Defend          int
+	AttackRefreshed time.Time
+	DefendRefreshed time.Time
+}
+
+// TeamBuff returns the effective buffs a team has on the primarch at now, see Buff.Active for duration
+func (p Prim) TeamBuff(team string, now time.Time, duration time.Duration) TeamBuff {
+	b := p.PrimarchBuffs[BuffTeam(team)]
+	return TeamBuff{
+		Team:            BuffTeam(team),
+		Attack:          b.Attack.Effective(now, duration),
+		Defend:          b.Defend.Effective(now, duration),
+		AttackRefreshed: b.Attack.TS.Time(),
+		DefendRefreshed: b.Defend.TS.Time(),
+	}
+}
+
+// TeamBuffs returns the effective buffs of every team on the primarch, strongest attack first
+func (p Prim) TeamBuffs(now time.Time, duration time.Duration) []TeamBuff {
+	ret := make([]TeamBuff, 0, len(p.PrimarchBuffs))
+	for team := range p.PrimarchBuffs {
+		ret = append(ret, p.TeamBuff(string(team), now, duration))
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Attack != ret[j].Attack {
+			return ret[i].Attack > ret[j].Attack
+		}
+		return ret[i].Team < ret[j].Team
+	})
+	return ret
+}
+
+// LastBuffRefresh returns when a team last refreshed any buff on the primarch
+func (p Prim) LastBuffRefresh(team string) (time.Time, bool) {
+	b, ok := p.PrimarchBuffs[BuffTeam(team)]
+	if !ok {
+		return time.Time{}, false
+	}
+	return b.LastRefreshed(), true
+}
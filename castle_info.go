@@ -66,21 +66,30 @@ type Executor struct {
 }
 
 type Prim struct {
-	PrimarchBuffs      map[string]Buffs
-	TauntProgress      int    `json:"taunt_progress"`
-	TauntEpoch         Epoch  `json:"taunt_epoch"`
-	AllianceName       string `json:"alliance_name"`
-	Level              int    `json:"level"`
-	PrimType           string `json:"dtype"`
-	TotalTroops        int    `json:"total_troops"`
-	BlockadeUntilEpoch Epoch  `json:"blockade_until_epoch"`
-	TauntThreshold     int    `json:"taunt_threshold"`
-	TeamName           string `json:"team_name"`
-	SummonEpoch        Epoch  `json:"summon_epoch"`
+	// PrimarchBuffs are the buffs on the primarch. The key is assumed to be the team that
+	// applied them, this is not confirmed by a captured response
+	PrimarchBuffs      map[BuffTeam]Buffs `json:"PrimarchBuffs"`
+	TauntProgress      int                `json:"taunt_progress"`
+	TauntEpoch         Epoch              `json:"taunt_epoch"`
+	AllianceName       string             `json:"alliance_name"`
+	Level              int                `json:"level"`
+	PrimType           string             `json:"dtype"`
+	TotalTroops        int                `json:"total_troops"`
+	BlockadeUntilEpoch Epoch              `json:"blockade_until_epoch"`
+	TauntThreshold     int                `json:"taunt_threshold"`
+	TeamName           string             `json:"team_name"`
+	SummonEpoch        Epoch              `json:"summon_epoch"`
+}
+
+// BuffTeam is the name of the team that applied a buff
+type BuffTeam string
+
+func (p Prim) Primarch() Primarch {
+	return Primarch{Type: p.PrimType, Level: p.Level}
 }
 
 func (p Prim) String() string {
-	return Primarch{Type: p.PrimType, Level: p.Level}.String()
+	return p.Primarch().String()
 }
 
 type Buffs struct {
@@ -0,0 +1,216 @@
+package wdapi
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContributionHistory stores contribution snapshots sorted by their timestamp
+type ContributionHistory struct {
+	Snapshots []Contribution
+}
+
+func (h *ContributionHistory) Add(c *Contribution) {
+	h.Snapshots = append(h.Snapshots, *c)
+	sort.SliceStable(h.Snapshots, func(i, j int) bool { return h.Snapshots[i].Timestamp < h.Snapshots[j].Timestamp })
+}
+
+// Latest returns the newest snapshot and the one before it
+func (h *ContributionHistory) Latest() (prev, next *Contribution, ok bool) {
+	n := len(h.Snapshots)
+	if n < 2 {
+		return nil, nil, false
+	}
+	return &h.Snapshots[n-2], &h.Snapshots[n-1], true
+}
+
+type ContributionDelta struct {
+	Player         string
+	Gold           float64
+	Mats           float64
+	Troops         float64
+	LifetimeTroops float64
+	// Current are the stats of the newer snapshot
+	Current Details
+	// New is true if the player was not in the older snapshot
+	New bool
+}
+
+// monthlyReset reports whether the monthly counters were reset between two snapshots,
+// i.e. whether they were taken in different months (UTC)
+func monthlyReset(prev, next Epoch) bool {
+	if prev.IsZero() || next.IsZero() {
+		return false
+	}
+	p, n := prev.Time().UTC(), next.Time().UTC()
+	return p.Year() != n.Year() || p.Month() != n.Month()
+}
+
+// monthlyDelta returns the growth of a monthly counter. After a reset the new value is the growth.
+// A counter that went down is treated as reset as well, e.g. for snapshots without timestamps
+func monthlyDelta(prev, next float64, reset bool) float64 {
+	if reset || next < prev {
+		return next
+	}
+	return next - prev
+}
+
+// DiffContributions computes per player deltas between two snapshots.
+// Players who left the team in between are not included
+func DiffContributions(prev, next *Contribution) []ContributionDelta {
+	reset := monthlyReset(prev.Timestamp, next.Timestamp)
+	before := make(map[string]Details)
+	for _, e := range prev.Entries {
+		before[e.Playername] = e.Stats
+	}
+	ret := []ContributionDelta{}
+	for _, e := range next.Entries {
+		was, ok := before[e.Playername]
+		d := ContributionDelta{
+			Player:         e.Playername,
+			Gold:           monthlyDelta(was.MonthlyGold, e.Stats.MonthlyGold, reset),
+			Mats:           monthlyDelta(was.MonthlyMats, e.Stats.MonthlyMats, reset),
+			Troops:         monthlyDelta(was.MonthlyTroops, e.Stats.MonthlyTroops, reset),
+			LifetimeTroops: e.Stats.LifetimeTroops - was.LifetimeTroops,
+			Current:        e.Stats,
+			New:            !ok,
+		}
+		ret = append(ret, d)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Player < ret[j].Player })
+	return ret
+}
+
+type ContributionThresholds struct {
+	Gold   float64
+	Mats   float64
+	Troops float64
+}
+
+// Below returns the deltas below any non zero threshold
+func (t ContributionThresholds) Below(deltas []ContributionDelta) []ContributionDelta {
+	ret := []ContributionDelta{}
+	for _, d := range deltas {
+		if (t.Gold > 0 && d.Gold < t.Gold) || (t.Mats > 0 && d.Mats < t.Mats) || (t.Troops > 0 && d.Troops < t.Troops) {
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+type ContributionMetric string
+
+const (
+	ContributionGold   ContributionMetric = "gold"
+	ContributionMats   ContributionMetric = "mats"
+	ContributionTroops ContributionMetric = "troops"
+)
+
+func (d ContributionDelta) value(m ContributionMetric) float64 {
+	switch m {
+	case ContributionGold:
+		return d.Gold
+	case ContributionMats:
+		return d.Mats
+	case ContributionTroops:
+		return d.Troops
+	}
+	return 0
+}
+
+// TopContributors returns the n players with the highest delta of the metric, n <= 0 returns all
+func TopContributors(deltas []ContributionDelta, m ContributionMetric, n int) []ContributionDelta {
+	ret := append([]ContributionDelta{}, deltas...)
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].value(m) > ret[j].value(m) })
+	if n > 0 && n < len(ret) {
+		ret = ret[:n]
+	}
+	return ret
+}
+
+// ContributionReport is the weekly report of a team
+type ContributionReport struct {
+	Title      string
+	Thresholds ContributionThresholds
+	Deltas     []ContributionDelta
+}
+
+func NewContributionReport(title string, prev, next *Contribution, thresholds ContributionThresholds) *ContributionReport {
+	return &ContributionReport{Title: title, Thresholds: thresholds, Deltas: DiffContributions(prev, next)}
+}
+
+var contributionHeader = []string{"Player", "Gold", "Mats", "Troops", "Lifetime Troops", "Below Threshold"}
+
+func (r *ContributionReport) rows() [][]string {
+	below := make(map[string]bool)
+	for _, d := range r.Thresholds.Below(r.Deltas) {
+		below[d.Player] = true
+	}
+	rows := [][]string{}
+	for _, d := range TopContributors(r.Deltas, ContributionTroops, 0) {
+		rows = append(rows, []string{
+			d.Player,
+			strconv.FormatFloat(d.Gold, 'f', 0, 64),
+			strconv.FormatFloat(d.Mats, 'f', 0, 64),
+			strconv.FormatFloat(d.Troops, 'f', 0, 64),
+			strconv.FormatFloat(d.LifetimeTroops, 'f', 0, 64),
+			strconv.FormatBool(below[d.Player]),
+		})
+	}
+	return rows
+}
+
+func (r *ContributionReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(contributionHeader); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(r.rows()); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func (r *ContributionReport) WriteMarkdown(w io.Writer) error {
+	b := strings.Builder{}
+	if r.Title != "" {
+		fmt.Fprintf(&b, "# %s\n\n", r.Title)
+	}
+	fmt.Fprintf(&b, "| %s |\n", strings.Join(contributionHeader, " | "))
+	fmt.Fprintf(&b, "|%s\n", strings.Repeat(" --- |", len(contributionHeader)))
+	escape := strings.NewReplacer("|", `\|`)
+	for _, row := range r.rows() {
+		row[0] = escape.Replace(row[0])
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(row, " | "))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var contributionHTML = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+{{if .Title}}<h1>{{.Title}}</h1>{{end}}
+<table>
+<thead><tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+</body>
+</html>
+`))
+
+func (r *ContributionReport) WriteHTML(w io.Writer) error {
+	return contributionHTML.Execute(w, struct {
+		Title  string
+		Header []string
+		Rows   [][]string
+	}{r.Title, contributionHeader, r.rows()})
+}
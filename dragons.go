@@ -0,0 +1,177 @@
+package wdapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type DragonRarity string
+
+const (
+	RarityCommon    DragonRarity = "common"
+	RarityUncommon  DragonRarity = "uncommon"
+	RarityRare      DragonRarity = "rare"
+	RarityEpic      DragonRarity = "epic"
+	RarityLegendary DragonRarity = "legendary"
+	RarityMythic    DragonRarity = "mythic"
+)
+
+var rarityRanks = map[DragonRarity]int{
+	RarityCommon:    1,
+	RarityUncommon:  2,
+	RarityRare:      3,
+	RarityEpic:      4,
+	RarityLegendary: 5,
+	RarityMythic:    6,
+}
+
+// Rank orders rarities from common (1) to mythic (6), 0 for unknown rarities
+func (r DragonRarity) Rank() int {
+	return rarityRanks[DragonRarity(strings.ToLower(string(r)))]
+}
+
+// AtLeast reports whether r is as rare as o or rarer
+func (r DragonRarity) AtLeast(o DragonRarity) bool {
+	return r.Rank() >= o.Rank() && r.Rank() > 0
+}
+
+// DragonInfo is the catalog entry of a dragon
+type DragonInfo struct {
+	ID      string       `json:"id"`
+	Name    string       `json:"name"`
+	Rarity  DragonRarity `json:"rarity"`
+	Element string       `json:"element"`
+	Tier    int          `json:"tier"`
+}
+
+// DragonCatalog maps dragon IDs to their metadata. It is safe for concurrent use
+type DragonCatalog struct {
+	mu      sync.RWMutex
+	dragons map[string]DragonInfo
+}
+
+type dragonCatalogFile struct {
+	Dragons []DragonInfo `json:"dragons"`
+}
+
+func NewDragonCatalog(dragons ...DragonInfo) *DragonCatalog {
+	c := &DragonCatalog{dragons: make(map[string]DragonInfo)}
+	c.Add(dragons...)
+	return c
+}
+
+// ReadDragonCatalog reads a catalog in the format of dragons.json
+func ReadDragonCatalog(r io.Reader) (*DragonCatalog, error) {
+	f := dragonCatalogFile{}
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	return NewDragonCatalog(f.Dragons...), nil
+}
+
+// LoadDragonCatalog reads a catalog from a file
+func LoadDragonCatalog(path string) (*DragonCatalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadDragonCatalog(f)
+}
+
+// Add adds or replaces entries
+func (c *DragonCatalog) Add(dragons ...DragonInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, d := range dragons {
+		c.dragons[d.ID] = d
+	}
+}
+
+// Merge adds or replaces the entries of o, e.g. to update the embedded catalog from a user file
+func (c *DragonCatalog) Merge(o *DragonCatalog) {
+	c.Add(o.List()...)
+}
+
+func (c *DragonCatalog) Get(id string) (DragonInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	d, ok := c.dragons[id]
+	return d, ok
+}
+
+// List returns all entries sorted by ID
+func (c *DragonCatalog) List() []DragonInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ret := make([]DragonInfo, 0, len(c.dragons))
+	for _, d := range c.dragons {
+		ret = append(ret, d)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return ret
+}
+
+func (c *DragonCatalog) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.dragons)
+}
+
+//go:embed dragons.json
+var embeddedDragons string
+
+// Dragons is the catalog used by the Dragon helpers.
+// It starts with the embedded dragons.json and can be extended with Add or Merge.
+//
+// The embedded dragons.json has no entries yet: the API does not document its dragon IDs.
+// Until a catalog is loaded, e.g. with Dragons.Merge(LoadDragonCatalog(path)),
+// Dragon.Name returns the raw ID and Dragon.Rarity is empty
+var Dragons = mustDragonCatalog(embeddedDragons)
+
+func mustDragonCatalog(s string) *DragonCatalog {
+	c, err := ReadDragonCatalog(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Name returns the display name of the dragon, or its ID if it is not in the catalog
+func (c *DragonCatalog) Name(d Dragon) string {
+	if info, ok := c.Get(d.ID); ok && info.Name != "" {
+		return info.Name
+	}
+	return d.ID
+}
+
+// Rarity returns the rarity of the dragon, empty if it is not in the catalog
+func (c *DragonCatalog) Rarity(d Dragon) DragonRarity {
+	info, _ := c.Get(d.ID)
+	return info.Rarity
+}
+
+// Info returns the entry of the dragon in Dragons
+func (d Dragon) Info() (DragonInfo, bool) {
+	return Dragons.Get(d.ID)
+}
+
+// Name returns the display name of the dragon in Dragons, or its ID if it is not in the catalog
+func (d Dragon) Name() string {
+	return Dragons.Name(d)
+}
+
+// Rarity returns the rarity of the dragon in Dragons, empty if it is not in the catalog
+func (d Dragon) Rarity() DragonRarity {
+	return Dragons.Rarity(d)
+}
+
+// IsRarity reports whether the dragon is at least as rare as r
+func (d Dragon) IsRarity(r DragonRarity) bool {
+	return d.Rarity().AtLeast(r)
+}
@@ -0,0 +1,178 @@
+package wdapi
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventKey identifies an atlas event by its start and type
+type EventKey struct {
+	Start Epoch
+	Type  string
+}
+
+func (e EventDetails) Key() EventKey {
+	return EventKey{Start: e.StartEpoch, Type: e.Type}
+}
+
+type ScorePoint struct {
+	At    time.Time
+	Score int
+}
+
+type PlayerEventScore struct {
+	Player  string
+	Team    string
+	Score   int
+	History []ScorePoint
+	// Projected is the extrapolated final score, 0 if the tracker has no event duration
+	Projected int
+}
+
+type TeamEventScore struct {
+	Team      string
+	Score     int
+	Projected int
+	Players   int
+}
+
+// EventTracker collects the event scores of many members over repeated polls.
+// It is safe for concurrent use
+type EventTracker struct {
+	// Duration is the length of an event, used to extrapolate final scores
+	Duration time.Duration
+	mu       sync.Mutex
+	events   map[EventKey]map[string]*PlayerEventScore
+}
+
+func NewEventTracker(duration time.Duration) *EventTracker {
+	return &EventTracker{Duration: duration, events: make(map[EventKey]map[string]*PlayerEventScore)}
+}
+
+// Record adds scores observed at the given time
+func (t *EventTracker) Record(at time.Time, scores []AtlasEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range scores {
+		k := s.EventDetails.Key()
+		players, ok := t.events[k]
+		if !ok {
+			players = make(map[string]*PlayerEventScore)
+			t.events[k] = players
+		}
+		p, ok := players[s.PlayerName]
+		if !ok {
+			p = &PlayerEventScore{Player: s.PlayerName}
+			players[s.PlayerName] = p
+		}
+		p.Team = s.TeamName
+		p.Score = s.Score
+		if n := len(p.History); n == 0 || !p.History[n-1].At.Equal(at) {
+			p.History = append(p.History, ScorePoint{At: at, Score: s.Score})
+		} else {
+			p.History[n-1].Score = s.Score
+		}
+	}
+}
+
+// Poll fetches the event scores of every key with at most concurrency requests in flight
+// and records them. concurrency defaults to 4.
+// Scores of the keys that worked are recorded even if some keys fail
+func (t *EventTracker) Poll(w WDAPI, apikeys []string, concurrency int) error {
+	at := w.now()
+	return fanOutKeys(apikeys, concurrency, func(_ int, k string) error {
+		res, err := w.GetEventScore(k)
+		if err != nil {
+			return err
+		}
+		t.Record(at, *res)
+		return nil
+	})
+}
+
+// Events returns all tracked events, newest first
+func (t *EventTracker) Events() []EventKey {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ret := make([]EventKey, 0, len(t.events))
+	for k := range t.events {
+		ret = append(ret, k)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Start != ret[j].Start {
+			return ret[i].Start > ret[j].Start
+		}
+		return ret[i].Type < ret[j].Type
+	})
+	return ret
+}
+
+// project extrapolates the final score from the observed rate,
+// or from the average rate since the event start if there is only one observation
+func (t *EventTracker) project(k EventKey, p *PlayerEventScore) int {
+	if t.Duration <= 0 || len(p.History) == 0 {
+		return 0
+	}
+	end := k.Start.Time().Add(t.Duration)
+	last := p.History[len(p.History)-1]
+	if !last.At.Before(end) {
+		return last.Score
+	}
+	first := ScorePoint{At: k.Start.Time()}
+	if len(p.History) > 1 {
+		first = p.History[0]
+	}
+	elapsed := last.At.Sub(first.At)
+	if elapsed <= 0 {
+		return last.Score
+	}
+	rate := float64(last.Score-first.Score) / float64(elapsed)
+	return last.Score + int(rate*float64(end.Sub(last.At)))
+}
+
+// Players returns the player leaderboard of an event, highest score first
+func (t *EventTracker) Players(k EventKey) []PlayerEventScore {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ret := []PlayerEventScore{}
+	for _, p := range t.events[k] {
+		v := *p
+		v.History = append([]ScorePoint{}, p.History...)
+		v.Projected = t.project(k, p)
+		ret = append(ret, v)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Score != ret[j].Score {
+			return ret[i].Score > ret[j].Score
+		}
+		return ret[i].Player < ret[j].Player
+	})
+	return ret
+}
+
+// Teams returns the team leaderboard of an event, highest score first
+func (t *EventTracker) Teams(k EventKey) []TeamEventScore {
+	teams := make(map[string]*TeamEventScore)
+	for _, p := range t.Players(k) {
+		s, ok := teams[p.Team]
+		if !ok {
+			s = &TeamEventScore{Team: p.Team}
+			teams[p.Team] = s
+		}
+		s.Score += p.Score
+		s.Projected += p.Projected
+		s.Players++
+	}
+	ret := make([]TeamEventScore, 0, len(teams))
+	for _, v := range teams {
+		ret = append(ret, *v)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Score != ret[j].Score {
+			return ret[i].Score > ret[j].Score
+		}
+		return ret[i].Team < ret[j].Team
+	})
+	return ret
+}
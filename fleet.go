@@ -0,0 +1,229 @@
+package wdapi
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TauntPercent returns the taunt progress between 0 and 100
+func (p Prim) TauntPercent() float64 {
+	if p.TauntThreshold <= 0 {
+		return 0
+	}
+	pct := float64(p.TauntProgress) / float64(p.TauntThreshold) * 100
+	if pct > 100 {
+		return 100
+	}
+	return pct
+}
+
+type FleetObservation struct {
+	At   time.Time
+	Prim Prim
+}
+
+// FleetTimeline is the history of one fleet of a castle across polls
+type FleetTimeline struct {
+	Castle       string
+	Fleet        string
+	Observations []FleetObservation
+	// Gone is when the fleet was first missing from a poll, zero while it is present
+	Gone time.Time
+}
+
+type FleetEventType string
+
+const (
+	FleetSummoned FleetEventType = "summoned"
+	FleetBlockade FleetEventType = "blockade"
+	FleetBuffs    FleetEventType = "buffs"
+	FleetTaunted  FleetEventType = "taunted"
+	FleetGone     FleetEventType = "gone"
+)
+
+type FleetEvent struct {
+	At      time.Time
+	Castle  string
+	Fleet   string
+	Type    FleetEventType
+	Until   time.Time
+	Message string
+}
+
+// FleetTracker builds per castle timelines of the primarch fleets from repeated GetCastleInfo polls
+type FleetTracker struct {
+	Timelines map[string]map[string]*FleetTimeline
+	events    []FleetEvent
+}
+
+func NewFleetTracker() *FleetTracker {
+	return &FleetTracker{Timelines: make(map[string]map[string]*FleetTimeline)}
+}
+
+// Observe records a poll and returns the events it caused
+func (t *FleetTracker) Observe(at time.Time, castles map[string]CastleInfo) []FleetEvent {
+	events := []FleetEvent{}
+	for id, c := range castles {
+		fleets, ok := t.Timelines[id]
+		if !ok {
+			fleets = make(map[string]*FleetTimeline)
+			t.Timelines[id] = fleets
+		}
+		for key, p := range c.Fleets {
+			tl, ok := fleets[key]
+			if !ok {
+				tl = &FleetTimeline{Castle: id, Fleet: key}
+				fleets[key] = tl
+			}
+			var prev *Prim
+			if n := len(tl.Observations); n > 0 {
+				prev = &tl.Observations[n-1].Prim
+			}
+			events = append(events, fleetEvents(id, key, at, prev, p)...)
+			tl.Gone = time.Time{}
+			tl.Observations = append(tl.Observations, FleetObservation{At: at, Prim: p})
+		}
+		for key, tl := range fleets {
+			if _, ok := c.Fleets[key]; ok || !tl.Gone.IsZero() || len(tl.Observations) == 0 {
+				continue
+			}
+			last := tl.Observations[len(tl.Observations)-1]
+			if last.At.Before(at) {
+				events = append(events, FleetEvent{At: at, Castle: id, Fleet: key, Type: FleetGone, Message: fmt.Sprintf("%s is gone", last.Prim)})
+				tl.Gone = at
+			}
+		}
+	}
+	sortFleetEvents(events)
+	t.events = append(t.events, events...)
+	return events
+}
+
+func fleetEvents(castle, fleet string, at time.Time, prev *Prim, now Prim) []FleetEvent {
+	ret := []FleetEvent{}
+	ev := func(typ FleetEventType, until time.Time, msg string) {
+		ret = append(ret, FleetEvent{At: at, Castle: castle, Fleet: fleet, Type: typ, Until: until, Message: msg})
+	}
+	if prev == nil || prev.SummonEpoch != now.SummonEpoch {
+		ev(FleetSummoned, time.Time{}, fmt.Sprintf("%s summoned by %s", now, now.TeamName))
+	}
+	if !now.BlockadeUntilEpoch.IsZero() && now.BlockadeUntilEpoch.Time().After(at) && (prev == nil || prev.BlockadeUntilEpoch != now.BlockadeUntilEpoch) {
+		ev(FleetBlockade, now.BlockadeUntilEpoch.Time(), fmt.Sprintf("%s blockaded until %s", now, now.BlockadeUntilEpoch.Time().UTC().Format(time.RFC1123)))
+	}
+	if now.TauntThreshold > 0 && now.TauntProgress >= now.TauntThreshold && (prev == nil || prev.TauntProgress < prev.TauntThreshold) {
+		ev(FleetTaunted, time.Time{}, fmt.Sprintf("%s taunt completed", now))
+	}
+	if prev != nil && !sameBuffs(prev.PrimarchBuffs, now.PrimarchBuffs) {
+		ev(FleetBuffs, time.Time{}, fmt.Sprintf("%s buffs changed", now))
+	}
+	return ret
+}
+
+func sameBuffs(a, b map[BuffTeam]Buffs) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+func sortFleetEvents(e []FleetEvent) {
+	sort.SliceStable(e, func(i, j int) bool {
+		if !e[i].At.Equal(e[j].At) {
+			return e[i].At.Before(e[j].At)
+		}
+		if e[i].Castle != e[j].Castle {
+			return e[i].Castle < e[j].Castle
+		}
+		return e[i].Fleet < e[j].Fleet
+	})
+}
+
+// Events returns all events observed so far, optionally filtered by castle
+func (t *FleetTracker) Events(castle string) []FleetEvent {
+	ret := []FleetEvent{}
+	for _, v := range t.events {
+		if castle == "" || v.Castle == castle {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// TauntRate returns the observed taunt progress per hour of the current summon.
+// ok is false if there are not at least two observations with progress
+func (tl *FleetTimeline) TauntRate() (float64, bool) {
+	n := len(tl.Observations)
+	if n < 2 {
+		return 0, false
+	}
+	last := tl.Observations[n-1]
+	first := last
+	for i := n - 2; i >= 0; i-- {
+		o := tl.Observations[i]
+		if o.Prim.SummonEpoch != last.Prim.SummonEpoch || o.Prim.TauntProgress > first.Prim.TauntProgress {
+			break
+		}
+		first = o
+	}
+	hours := last.At.Sub(first.At).Hours()
+	if hours <= 0 || last.Prim.TauntProgress <= first.Prim.TauntProgress {
+		return 0, false
+	}
+	return float64(last.Prim.TauntProgress-first.Prim.TauntProgress) / hours, true
+}
+
+// PredictTaunt estimates when the taunt completes at the observed rate
+func (tl *FleetTimeline) PredictTaunt() (time.Time, bool) {
+	if len(tl.Observations) == 0 {
+		return time.Time{}, false
+	}
+	last := tl.Observations[len(tl.Observations)-1]
+	if last.Prim.TauntThreshold > 0 && last.Prim.TauntProgress >= last.Prim.TauntThreshold {
+		return last.At, true
+	}
+	rate, ok := tl.TauntRate()
+	if !ok || last.Prim.TauntThreshold <= 0 {
+		return time.Time{}, false
+	}
+	left := float64(last.Prim.TauntThreshold - last.Prim.TauntProgress)
+	return last.At.Add(time.Duration(left / rate * float64(time.Hour))), true
+}
+
+type TauntPrediction struct {
+	Castle  string
+	Fleet   string
+	Prim    Prim
+	Percent float64
+	ETA     time.Time
+}
+
+// Next returns the predicted taunt completions of all fleets, soonest first
+func (t *FleetTracker) Next() []TauntPrediction {
+	ret := []TauntPrediction{}
+	for _, fleets := range t.Timelines {
+		for _, tl := range fleets {
+			if !tl.Gone.IsZero() {
+				continue
+			}
+			eta, ok := tl.PredictTaunt()
+			if !ok {
+				continue
+			}
+			last := tl.Observations[len(tl.Observations)-1].Prim
+			ret = append(ret, TauntPrediction{Castle: tl.Castle, Fleet: tl.Fleet, Prim: last, Percent: last.TauntPercent(), ETA: eta})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if !ret[i].ETA.Equal(ret[j].ETA) {
+			return ret[i].ETA.Before(ret[j].ETA)
+		}
+		return ret[i].Castle+ret[i].Fleet < ret[j].Castle+ret[j].Fleet
+	})
+	return ret
+}
@@ -0,0 +1,165 @@
+package wdapi
+
+import (
+	"sort"
+	"time"
+)
+
+// GeoJSON types. Positions are in map units as shown in game,
+// so the files are meant to be loaded with a plain cartesian CRS
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type Feature struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+func newFeatureCollection() *FeatureCollection {
+	return &FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+}
+
+// Position returns the coordinates as a GeoJSON position in map units
+func (c Coords) Position() []float64 {
+	x, y := c.Map()
+	return []float64{x, y}
+}
+
+// CastlesGeoJSON exports every castle as a point feature.
+// info and alliances are optional, details from info take precedence over the macro
+func CastlesGeoJSON(m *CastlesMacro, info map[string]CastleInfo, alliances *Alliances) *FeatureCollection {
+	fc := newFeatureCollection()
+	idx := alliances.Index()
+
+	ids := make([]string, 0, len(m.Castles))
+	for id := range m.Castles {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		c := m.Castles[id]
+		alliance, _ := idx.AllianceOf(c.OwnerTeam)
+		props := map[string]interface{}{
+			"owner_team": c.OwnerTeam,
+			"alliance":   alliance,
+			"level":      c.Level,
+		}
+		pid, err := ParsePlaceID(id)
+		ok := err == nil
+		if ci, found := info[id]; found {
+			pid, ok = ci.PlaceID, true
+			props["owner_team"] = ci.OwnerTeam
+			props["level"] = ci.Level
+			props["custom_name"] = ci.CustomName
+			if ci.OwnerAlliance != "" {
+				props["alliance"] = ci.OwnerAlliance
+			}
+			if !ci.OwnedSinceEpoch.IsZero() {
+				props["owned_since"] = ci.OwnedSinceEpoch.Time().UTC().Format(time.RFC3339)
+			}
+		}
+		if ok {
+			props["kingdom"] = pid.KingdomID
+			props["region"] = pid.RegionID
+			props["index"] = pid.ContIDX
+		}
+		fc.Features = append(fc.Features, Feature{
+			Type:       "Feature",
+			ID:         id,
+			Geometry:   Geometry{Type: "Point", Coordinates: c.Coords.Position()},
+			Properties: props,
+		})
+	}
+	return fc
+}
+
+func groupedGeoJSON(m *CastlesMacro, group func(Castle) string, kind string) *FeatureCollection {
+	fc := newFeatureCollection()
+	groups := make(map[string][]string)
+	for id, c := range m.Castles {
+		if name := group(c); name != "" {
+			groups[name] = append(groups[name], id)
+		}
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		ids := groups[name]
+		sort.Strings(ids)
+		points := make([][]float64, 0, len(ids))
+		levels := 0
+		for _, id := range ids {
+			points = append(points, m.Castles[id].Coords.Position())
+			levels += m.Castles[id].Level
+		}
+		fc.Features = append(fc.Features, Feature{
+			Type:     "Feature",
+			ID:       name,
+			Geometry: Geometry{Type: "MultiPoint", Coordinates: points},
+			Properties: map[string]interface{}{
+				kind:           name,
+				"castles":      len(ids),
+				"total_levels": levels,
+				"castle_ids":   ids,
+			},
+		})
+	}
+	return fc
+}
+
+// TeamsGeoJSON exports the castles of every team as one MultiPoint feature
+func TeamsGeoJSON(m *CastlesMacro) *FeatureCollection {
+	return groupedGeoJSON(m, func(c Castle) string { return c.OwnerTeam }, "team")
+}
+
+// AlliancesGeoJSON exports the castles of every alliance as one MultiPoint feature
+func AlliancesGeoJSON(m *CastlesMacro, alliances *Alliances) *FeatureCollection {
+	idx := alliances.Index()
+	return groupedGeoJSON(m, func(c Castle) string {
+		a, _ := idx.AllianceOf(c.OwnerTeam)
+		return a
+	}, "alliance")
+}
+
+// TerritoryGeoJSON exports the territory of every group as one MultiPolygon feature
+func TerritoryGeoJSON(t *Territory) *FeatureCollection {
+	fc := newFeatureCollection()
+	for _, s := range t.Ranking() {
+		polys := make([][][][]float64, 0, len(s.Polygons))
+		for _, p := range s.Polygons {
+			ring := make([][]float64, 0, len(p)+1)
+			for _, c := range p {
+				ring = append(ring, c.Position())
+			}
+			if len(p) > 0 {
+				ring = append(ring, p[0].Position())
+			}
+			polys = append(polys, [][][]float64{ring})
+		}
+		fc.Features = append(fc.Features, Feature{
+			Type:     "Feature",
+			ID:       s.Name,
+			Geometry: Geometry{Type: "MultiPolygon", Coordinates: polys},
+			Properties: map[string]interface{}{
+				"name":  s.Name,
+				"area":  s.Area,
+				"share": s.Share,
+			},
+		})
+	}
+	return fc
+}
@@ -0,0 +1,201 @@
+package wdapi
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// MapScale is the number of raw units per map unit. The raw Y axis points down, the map Y axis up
+const MapScale = 40
+
+// Map returns the coordinates in map units as shown in game
+func (c Coords) Map() (float64, float64) {
+	return c.X / MapScale, c.Y / -MapScale
+}
+
+// CoordsFromMap converts map units back into raw coordinates
+func CoordsFromMap(x, y float64) Coords {
+	return Coords{X: x * MapScale, Y: y * -MapScale}
+}
+
+// Distance returns the straight line distance in map units
+func (c Coords) Distance(o Coords) float64 {
+	return math.Hypot(o.X-c.X, o.Y-c.Y) / MapScale
+}
+
+// Bearing returns the direction from c to o in degrees clockwise from map north, in [0, 360)
+func (c Coords) Bearing(o Coords) float64 {
+	x1, y1 := c.Map()
+	x2, y2 := o.Map()
+	deg := math.Atan2(x2-x1, y2-y1) * 180 / math.Pi
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
+
+// TravelTime estimates the time to get from c to o at speed map units per hour
+func (c Coords) TravelTime(o Coords, speed float64) time.Duration {
+	if speed <= 0 {
+		return 0
+	}
+	return time.Duration(c.Distance(o) / speed * float64(time.Hour))
+}
+
+type CastleDistance struct {
+	ID       string
+	Castle   Castle
+	Distance float64
+}
+
+// CastlesWithin returns all castles within r map units of center, nearest first.
+// For repeated lookups over the same castles use a CastleIndex
+func CastlesWithin(castles map[string]Castle, center Coords, r float64) []CastleDistance {
+	ret := []CastleDistance{}
+	for id, v := range castles {
+		if d := center.Distance(v.Coords); d <= r {
+			ret = append(ret, CastleDistance{ID: id, Castle: v, Distance: d})
+		}
+	}
+	sortCastleDistances(ret)
+	return ret
+}
+
+func sortCastleDistances(c []CastleDistance) {
+	sort.Slice(c, func(i, j int) bool {
+		if c[i].Distance != c[j].Distance {
+			return c[i].Distance < c[j].Distance
+		}
+		return c[i].ID < c[j].ID
+	})
+}
+
+type cell struct {
+	x, y int
+}
+
+// CastleIndex is a grid based spatial index over castles for radius and nearest neighbour lookups
+type CastleIndex struct {
+	size    float64
+	cells   map[cell][]string
+	castles map[string]Castle
+	min     cell
+	max     cell
+}
+
+// NewCastleIndex indexes castles in a grid with cells of size map units.
+// size defaults to 10 map units
+func NewCastleIndex(castles map[string]Castle, size float64) *CastleIndex {
+	if size <= 0 {
+		size = 10
+	}
+	idx := &CastleIndex{
+		size:    size,
+		cells:   make(map[cell][]string),
+		castles: castles,
+	}
+	first := true
+	for id, v := range castles {
+		c := idx.cellOf(v.Coords)
+		idx.cells[c] = append(idx.cells[c], id)
+		if first {
+			idx.min, idx.max = c, c
+			first = false
+			continue
+		}
+		if c.x < idx.min.x {
+			idx.min.x = c.x
+		}
+		if c.y < idx.min.y {
+			idx.min.y = c.y
+		}
+		if c.x > idx.max.x {
+			idx.max.x = c.x
+		}
+		if c.y > idx.max.y {
+			idx.max.y = c.y
+		}
+	}
+	return idx
+}
+
+// Index builds a CastleIndex over all castles of the macro
+func (m CastlesMacro) Index() *CastleIndex {
+	return NewCastleIndex(m.Castles, 0)
+}
+
+func (i *CastleIndex) cellOf(c Coords) cell {
+	x, y := c.Map()
+	return cell{x: int(math.Floor(x / i.size)), y: int(math.Floor(y / i.size))}
+}
+
+// Within returns all castles within r map units of center, nearest first
+func (i *CastleIndex) Within(center Coords, r float64) []CastleDistance {
+	ret := []CastleDistance{}
+	if len(i.castles) == 0 {
+		return ret
+	}
+	// only scan the occupied cells, a large r would otherwise loop over empty ones
+	x, y := center.Map()
+	clamp := func(v float64, lo, hi int) int {
+		return int(math.Max(float64(lo), math.Min(float64(hi), math.Floor(v/i.size))))
+	}
+	lo := cell{x: clamp(x-r, i.min.x, i.max.x), y: clamp(y-r, i.min.y, i.max.y)}
+	hi := cell{x: clamp(x+r, i.min.x, i.max.x), y: clamp(y+r, i.min.y, i.max.y)}
+	for cx := lo.x; cx <= hi.x; cx++ {
+		for cy := lo.y; cy <= hi.y; cy++ {
+			for _, id := range i.cells[cell{x: cx, y: cy}] {
+				v := i.castles[id]
+				if d := center.Distance(v.Coords); d <= r {
+					ret = append(ret, CastleDistance{ID: id, Castle: v, Distance: d})
+				}
+			}
+		}
+	}
+	sortCastleDistances(ret)
+	return ret
+}
+
+// Nearest returns the castle closest to center for which keep returns true.
+// A nil keep accepts every castle
+func (i *CastleIndex) Nearest(center Coords, keep func(id string, c Castle) bool) (CastleDistance, bool) {
+	if len(i.castles) == 0 {
+		return CastleDistance{}, false
+	}
+	origin := i.cellOf(center)
+	rings := 0
+	for _, d := range []int{origin.x - i.min.x, i.max.x - origin.x, origin.y - i.min.y, i.max.y - origin.y} {
+		if d > rings {
+			rings = d
+		}
+	}
+
+	best := CastleDistance{Distance: math.Inf(1)}
+	found := false
+	for k := 0; k <= rings; k++ {
+		// castles in ring k and beyond are at least k-1 cells away from center
+		if found && best.Distance <= float64(k-1)*i.size {
+			break
+		}
+		for cx := origin.x - k; cx <= origin.x+k; cx++ {
+			for cy := origin.y - k; cy <= origin.y+k; cy++ {
+				if cx != origin.x-k && cx != origin.x+k && cy != origin.y-k && cy != origin.y+k {
+					continue
+				}
+				for _, id := range i.cells[cell{x: cx, y: cy}] {
+					v := i.castles[id]
+					if keep != nil && !keep(id, v) {
+						continue
+					}
+					d := center.Distance(v.Coords)
+					if d < best.Distance || (d == best.Distance && id < best.ID) {
+						best = CastleDistance{ID: id, Castle: v, Distance: d}
+						found = true
+					}
+				}
+			}
+		}
+	}
+	return best, found
+}
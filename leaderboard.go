@@ -0,0 +1,219 @@
+package wdapi
+
+import (
+	"sort"
+)
+
+type Metric string
+
+const (
+	MetricElo        Metric = "elo"
+	MetricRank       Metric = "rank"
+	MetricPowerRank  Metric = "power_rank"
+	MetricInfluence  Metric = "influence"
+	MetricActiveness Metric = "activeness"
+	MetricKills      Metric = "monthly_kills"
+	MetricCastles    Metric = "castles"
+	MetricRoster     Metric = "roster"
+	MetricScore      Metric = "score"
+)
+
+// DefaultWeights returns the weights used for the composite score if none are given
+func DefaultWeights() map[Metric]float64 {
+	return map[Metric]float64{
+		MetricElo:       1,
+		MetricInfluence: 1,
+		MetricKills:     1,
+		MetricCastles:   1,
+	}
+}
+
+// lowerIsBetter reports whether smaller values of m are better, as for the API ranks
+func lowerIsBetter(m Metric) bool {
+	return m == MetricRank || m == MetricPowerRank
+}
+
+type TeamStanding struct {
+	Team         string
+	Elo          int
+	Rank         int
+	PowerRank    int
+	Influence    int
+	Activeness   float64
+	MonthlyKills int
+	Castles      int
+	Roster       int
+	Score        float64
+	// Position is the 1 based position after the last sort
+	Position int
+}
+
+// Value returns the raw value of a metric
+func (s TeamStanding) Value(m Metric) float64 {
+	switch m {
+	case MetricElo:
+		return float64(s.Elo)
+	case MetricRank:
+		return float64(s.Rank)
+	case MetricPowerRank:
+		return float64(s.PowerRank)
+	case MetricInfluence:
+		return float64(s.Influence)
+	case MetricActiveness:
+		return s.Activeness
+	case MetricKills:
+		return float64(s.MonthlyKills)
+	case MetricCastles:
+		return float64(s.Castles)
+	case MetricRoster:
+		return float64(s.Roster)
+	case MetricScore:
+		return s.Score
+	}
+	return 0
+}
+
+type Leaderboard struct {
+	Timestamp Epoch
+	Standings []TeamStanding
+}
+
+// BuildLeaderboard joins the teams macro with monthly kills, castle counts and roster sizes.
+// kills, castles and meta are optional. weights configures the composite score, nil uses DefaultWeights.
+// Every metric is scaled to [0, 1] over all teams before weighting.
+// The result is sorted by score
+func BuildLeaderboard(teams *TeamsMacro, kills map[string]TeamKills, castles *CastlesMacro, meta map[string]TeamMetadata, weights map[Metric]float64) *Leaderboard {
+	if weights == nil {
+		weights = DefaultWeights()
+	}
+	owned := make(map[string]int)
+	if castles != nil {
+		for _, c := range castles.Castles {
+			owned[c.OwnerTeam]++
+		}
+	}
+
+	l := &Leaderboard{Timestamp: teams.Timestamp}
+	for name, t := range teams.Teams {
+		s := TeamStanding{
+			Team:         name,
+			Elo:          t.Elo,
+			Rank:         t.Rank,
+			PowerRank:    t.PowerRank,
+			Influence:    t.Influence,
+			Activeness:   float64(t.Activeness.Score),
+			MonthlyKills: kills[name].TotalKills,
+			Castles:      owned[name],
+			Roster:       len(meta[name].Roster),
+		}
+		l.Standings = append(l.Standings, s)
+	}
+	l.Rescore(weights)
+	return l
+}
+
+// Rescore recomputes the composite score with new weights and sorts by it
+func (l *Leaderboard) Rescore(weights map[Metric]float64) {
+	total := 0.0
+	for m, w := range weights {
+		if m != MetricScore {
+			total += w
+		}
+	}
+	for i := range l.Standings {
+		l.Standings[i].Score = 0
+	}
+	if total > 0 {
+		for m, w := range weights {
+			if m == MetricScore || w == 0 {
+				continue
+			}
+			lo, hi := 0.0, 0.0
+			for i, s := range l.Standings {
+				v := s.Value(m)
+				if i == 0 || v < lo {
+					lo = v
+				}
+				if i == 0 || v > hi {
+					hi = v
+				}
+			}
+			for i := range l.Standings {
+				norm := 1.0
+				if hi > lo {
+					norm = (l.Standings[i].Value(m) - lo) / (hi - lo)
+				}
+				if lowerIsBetter(m) {
+					norm = 1 - norm
+				}
+				l.Standings[i].Score += w * norm / total
+			}
+		}
+	}
+	l.SortBy(MetricScore)
+}
+
+// SortBy sorts the standings best first by the metric and updates Position
+func (l *Leaderboard) SortBy(m Metric) {
+	sort.SliceStable(l.Standings, func(i, j int) bool {
+		a, b := l.Standings[i].Value(m), l.Standings[j].Value(m)
+		if a != b {
+			if lowerIsBetter(m) {
+				return a < b
+			}
+			return a > b
+		}
+		return l.Standings[i].Team < l.Standings[j].Team
+	})
+	for i := range l.Standings {
+		l.Standings[i].Position = i + 1
+	}
+}
+
+// Ranked returns a copy of the standings sorted by the metric
+func (l *Leaderboard) Ranked(m Metric) []TeamStanding {
+	c := &Leaderboard{Timestamp: l.Timestamp, Standings: append([]TeamStanding{}, l.Standings...)}
+	c.SortBy(m)
+	return c.Standings
+}
+
+// Team returns the standing of a single team
+func (l *Leaderboard) Team(name string) (TeamStanding, bool) {
+	for _, v := range l.Standings {
+		if v.Team == name {
+			return v, true
+		}
+	}
+	return TeamStanding{}, false
+}
+
+type RankDelta struct {
+	Team     string
+	Position int
+	// Previous is 0 for teams that were not on the previous leaderboard
+	Previous int
+	// Change is positive for teams that moved up
+	Change int
+	Value  float64
+	// ValueChange is the change of the metric's raw value
+	ValueChange float64
+}
+
+// Deltas compares the positions by metric m with a previous leaderboard
+func (l *Leaderboard) Deltas(prev *Leaderboard, m Metric) []RankDelta {
+	before := make(map[string]TeamStanding)
+	for _, v := range prev.Ranked(m) {
+		before[v.Team] = v
+	}
+	ret := []RankDelta{}
+	for _, v := range l.Ranked(m) {
+		d := RankDelta{Team: v.Team, Position: v.Position, Value: v.Value(m)}
+		if p, ok := before[v.Team]; ok {
+			d.Previous = p.Position
+			d.Change = p.Position - v.Position
+			d.ValueChange = v.Value(m) - p.Value(m)
+		}
+		ret = append(ret, d)
+	}
+	return ret
+}
@@ -0,0 +1,300 @@
+package wdapi
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Localizer turns message keys into text in a specific language
+type Localizer interface {
+	Language() string
+	// Sprintf formats the message for key with args.
+	// Unknown keys fall back to English and then to the key itself
+	Sprintf(key string, args ...interface{}) string
+	// FormatNumber formats f with the given number of decimals using the locale's separators
+	FormatNumber(f float64, decimals int) string
+}
+
+// Catalog is a Localizer backed by a map of printf style messages
+type Catalog struct {
+	Lang       string
+	Messages   map[string]string
+	DecimalSep string
+	GroupSep   string
+}
+
+func (c *Catalog) Language() string {
+	return c.Lang
+}
+
+func (c *Catalog) Sprintf(key string, args ...interface{}) string {
+	msg, ok := c.Messages[key]
+	if !ok {
+		msg, ok = English.Messages[key]
+	}
+	if !ok {
+		return key
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+func (c *Catalog) FormatNumber(f float64, decimals int) string {
+	if decimals < 0 {
+		decimals = 0
+	}
+	s := fmt.Sprintf("%.*f", decimals, math.Abs(f))
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+
+	b := strings.Builder{}
+	if f < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(c.GroupSep)
+		}
+		b.WriteRune(r)
+	}
+	if frac != "" {
+		b.WriteString(c.DecimalSep)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+var (
+	English = &Catalog{
+		Lang:       "en",
+		DecimalSep: ".",
+		GroupSep:   ",",
+		Messages: map[string]string{
+			"time.ago":         "%s ago",
+			"time.in":          "in %s",
+			"time.now":         "now",
+			"time.never":       "never",
+			"unit.day":         "%dd",
+			"unit.hour":        "%dh",
+			"unit.minute":      "%dm",
+			"unit.second":      "%ds",
+			"coords":           "X:%s Y:%s",
+			"primarch":         "LVL %[1]d %[2]s %[3]s",
+			"primarch.fort":    "Fort level %d",
+			"primarch.unknown": "Unknown: %s",
+			"class.Fort":       "Fort",
+			"class.Trapper":    "Trapper",
+			"class.Taunter":    "Taunter",
+			"class.Destroyer":  "Destroyer",
+			"class.Sieger":     "Sieger",
+			"tier.Bronze":      "Bronze",
+			"tier.Silver 1":    "Silver 1",
+			"tier.Silver 2":    "Silver 2",
+			"tier.Gold 1":      "Gold 1",
+			"tier.Gold 2":      "Gold 2",
+		},
+	}
+
+	German = &Catalog{
+		Lang:       "de",
+		DecimalSep: ",",
+		GroupSep:   ".",
+		Messages: map[string]string{
+			"time.ago":         "vor %s",
+			"time.in":          "in %s",
+			"time.now":         "jetzt",
+			"time.never":       "nie",
+			"unit.day":         "%d T",
+			"unit.hour":        "%d Std",
+			"unit.minute":      "%d Min",
+			"unit.second":      "%d Sek",
+			"coords":           "X:%s Y:%s",
+			"primarch":         "Stufe %[1]d %[3]s %[2]s",
+			"primarch.fort":    "Festung Stufe %d",
+			"primarch.unknown": "Unbekannt: %s",
+			"class.Fort":       "Festung",
+			"class.Trapper":    "Fallensteller",
+			"class.Taunter":    "Provokateur",
+			"class.Destroyer":  "Zerstörer",
+			"class.Sieger":     "Belagerer",
+			"tier.Bronze":      "Bronze",
+			"tier.Silver 1":    "Silber 1",
+			"tier.Silver 2":    "Silber 2",
+			"tier.Gold 1":      "Gold 1",
+			"tier.Gold 2":      "Gold 2",
+		},
+	}
+
+	French = &Catalog{
+		Lang:       "fr",
+		DecimalSep: ",",
+		GroupSep:   " ",
+		Messages: map[string]string{
+			"time.ago":         "il y a %s",
+			"time.in":          "dans %s",
+			"time.now":         "maintenant",
+			"time.never":       "jamais",
+			"unit.day":         "%d j",
+			"unit.hour":        "%d h",
+			"unit.minute":      "%d min",
+			"unit.second":      "%d s",
+			"coords":           "X : %s Y : %s",
+			"primarch":         "%[3]s %[2]s niv. %[1]d",
+			"primarch.fort":    "Fort niveau %d",
+			"primarch.unknown": "Inconnu : %s",
+			"class.Fort":       "Fort",
+			"class.Trapper":    "Piégeur",
+			"class.Taunter":    "Provocateur",
+			"class.Destroyer":  "Destructeur",
+			"class.Sieger":     "Assiégeur",
+			"tier.Bronze":      "Bronze",
+			"tier.Silver 1":    "Argent 1",
+			"tier.Silver 2":    "Argent 2",
+			"tier.Gold 1":      "Or 1",
+			"tier.Gold 2":      "Or 2",
+		},
+	}
+
+	catalogmu sync.RWMutex
+	catalogs  = map[string]Localizer{
+		"en":       English,
+		"english":  English,
+		"de":       German,
+		"german":   German,
+		"deutsch":  German,
+		"fr":       French,
+		"french":   French,
+		"français": French,
+		"francais": French,
+	}
+)
+
+// RegisterLocalizer makes l available through LocalizerFor under its language and any aliases
+func RegisterLocalizer(l Localizer, aliases ...string) {
+	catalogmu.Lock()
+	defer catalogmu.Unlock()
+	catalogs[strings.ToLower(l.Language())] = l
+	for _, v := range aliases {
+		catalogs[strings.ToLower(v)] = l
+	}
+}
+
+// LocalizerFor returns the localizer for a language as found in Profile.Language.
+// It accepts plain codes ("de"), regional codes ("de_DE", "de-AT") and registered names ("German").
+// Unknown languages fall back to English
+func LocalizerFor(lang string) Localizer {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	catalogmu.RLock()
+	defer catalogmu.RUnlock()
+	if l, ok := catalogs[lang]; ok {
+		return l
+	}
+	if i := strings.IndexAny(lang, "_-"); i > 0 {
+		if l, ok := catalogs[lang[:i]]; ok {
+			return l
+		}
+	}
+	return English
+}
+
+// Localizer returns the localizer matching the profile's language
+func (p Profile) Localizer() Localizer {
+	return LocalizerFor(p.Language)
+}
+
+// FormatDuration formats d in whole seconds using the localized unit names, e.g. "1d 2h 3m 4s"
+func FormatDuration(l Localizer, d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	d = d.Truncate(time.Second)
+	if d == 0 {
+		return l.Sprintf("unit.second", 0)
+	}
+	parts := []string{}
+	units := []struct {
+		key  string
+		size time.Duration
+	}{
+		{"unit.day", 24 * time.Hour},
+		{"unit.hour", time.Hour},
+		{"unit.minute", time.Minute},
+		{"unit.second", time.Second},
+	}
+	for _, u := range units {
+		if n := d / u.size; n > 0 {
+			parts = append(parts, l.Sprintf(u.key, int64(n)))
+			d -= n * u.size
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// FormatRelative formats t relative to now, e.g. "2h ago" or "in 5m"
+func FormatRelative(l Localizer, t, now time.Time) string {
+	d := t.Sub(now).Truncate(time.Second)
+	switch {
+	case d == 0:
+		return l.Sprintf("time.now")
+	case d > 0:
+		return l.Sprintf("time.in", FormatDuration(l, d))
+	}
+	return l.Sprintf("time.ago", FormatDuration(l, d))
+}
+
+func localizedName(l Localizer, prefix, name string) string {
+	key := prefix + name
+	if s := l.Sprintf(key); s != key {
+		return s
+	}
+	return name
+}
+
+// Localize is the localized form of String
+func (p Primarch) Localize(l Localizer) string {
+	info, ok := p.Info()
+	if !ok {
+		return l.Sprintf("primarch.unknown", p.Type)
+	}
+	if info.IsFort() {
+		return l.Sprintf("primarch.fort", info.Level)
+	}
+	return l.Sprintf("primarch", info.Level, localizedName(l, "tier.", info.Tier.String()), localizedName(l, "class.", info.Class.String()))
+}
+
+func localizeTime(l Localizer, t APITime, now time.Time) string {
+	if t.IsZero() {
+		return l.Sprintf("time.never")
+	}
+	return FormatRelative(l, t.Time(), now)
+}
+
+// Localize is the localized form of String
+func (e Epoch) Localize(l Localizer) string {
+	return e.LocalizeRelativeTo(l, time.Now())
+}
+
+// LocalizeRelativeTo is the localized form of RelativeTo
+func (e Epoch) LocalizeRelativeTo(l Localizer, now time.Time) string {
+	return localizeTime(l, e, now)
+}
+
+// Localize is the localized form of String
+func (p PGTS) Localize(l Localizer) string {
+	return p.LocalizeRelativeTo(l, time.Now())
+}
+
+// LocalizeRelativeTo is the localized form of RelativeTo
+func (p PGTS) LocalizeRelativeTo(l Localizer, now time.Time) string {
+	return localizeTime(l, p, now)
+}
+
+// Localize is the localized form of String
+func (c Coords) Localize(l Localizer) string {
+	x, y := c.Map()
+	return l.Sprintf("coords", l.FormatNumber(x, 1), l.FormatNumber(y, 1))
+}
@@ -0,0 +1,122 @@
+package wdapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocalizerFor(t *testing.T) {
+	cases := map[string]Localizer{
+		"de":      German,
+		"de_DE":   German,
+		"fr-CA":   French,
+		"French":  French,
+		"en":      English,
+		"klingon": English,
+		"":        English,
+	}
+	for lang, want := range cases {
+		if have := LocalizerFor(lang); have != want {
+			t.Errorf("%s: have '%s' want '%s'", lang, have.Language(), want.Language())
+		}
+	}
+}
+
+func TestLocalizePrimarch(t *testing.T) {
+	p := Primarch{Type: "sieger5", Level: 25}
+	cases := map[Localizer]string{
+		English: "LVL 25 Gold 2 Sieger",
+		German:  "Stufe 25 Belagerer Gold 2",
+		French:  "Assiégeur Or 2 niv. 25",
+	}
+	for l, want := range cases {
+		if have := p.Localize(l); have != want {
+			t.Errorf("have '%s' want '%s'", have, want)
+		}
+	}
+	if have := p.Localize(English); have != p.String() {
+		t.Errorf("have '%s' want '%s'", have, p.String())
+	}
+	if have := (Primarch{Type: "garrison", Level: 3}).Localize(German); have != "Festung Stufe 3" {
+		t.Errorf("have '%s' want '%s'", have, "Festung Stufe 3")
+	}
+	if have := (Primarch{Type: "nope"}).Localize(French); have != "Inconnu : nope" {
+		t.Errorf("have '%s' want '%s'", have, "Inconnu : nope")
+	}
+}
+
+func TestFormatRelative(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	past := now.Add(-(26*time.Hour + 5*time.Minute))
+	future := now.Add(90 * time.Second)
+	cases := []struct {
+		l    Localizer
+		t    time.Time
+		want string
+	}{
+		{English, past, "1d 2h 5m ago"},
+		{English, future, "in 1m 30s"},
+		{German, past, "vor 1 T 2 Std 5 Min"},
+		{German, future, "in 1 Min 30 Sek"},
+		{French, past, "il y a 1 j 2 h 5 min"},
+		{French, future, "dans 1 min 30 s"},
+		{French, now, "maintenant"},
+	}
+	for _, v := range cases {
+		if have := FormatRelative(v.l, v.t, now); have != v.want {
+			t.Errorf("have '%s' want '%s'", have, v.want)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	cases := []struct {
+		l        Localizer
+		f        float64
+		decimals int
+		want     string
+	}{
+		{English, 1234567.891, 2, "1,234,567.89"},
+		{German, 1234567.891, 2, "1.234.567,89"},
+		{French, 1234567.891, 1, "1 234 567,9"},
+		{English, -1234, 0, "-1,234"},
+		{English, 999, 0, "999"},
+		{German, -0.01, 1, "0,0"},
+	}
+	for _, v := range cases {
+		if have := v.l.FormatNumber(v.f, v.decimals); have != v.want {
+			t.Errorf("have '%s' want '%s'", have, v.want)
+		}
+	}
+
+	c := Coords{X: 400, Y: -1234}
+	if have := c.Localize(German); have != "X:10,0 Y:30,9" {
+		t.Errorf("have '%s' want '%s'", have, "X:10,0 Y:30,9")
+	}
+}
+
+func TestLocalizeRelativeTo(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cases := []struct {
+		l    Localizer
+		t    APITime
+		want string
+	}{
+		{German, EpochFromTime(now.Add(-90 * time.Minute)), "vor 1 Std 30 Min"},
+		{German, PGTSFromTime(now.Add(2 * time.Hour)), "in 2 Std"},
+		{French, EpochFromTime(now.Add(-26 * time.Hour)), "il y a 1 j 2 h"},
+		{French, PGTS(0), "jamais"},
+	}
+	for _, c := range cases {
+		var have string
+		switch v := c.t.(type) {
+		case Epoch:
+			have = v.LocalizeRelativeTo(c.l, now)
+		case PGTS:
+			have = v.LocalizeRelativeTo(c.l, now)
+		}
+		if have != c.want {
+			t.Errorf("have '%s' want '%s'", have, c.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package wdapi
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Infrastructure returns the fields the port shares with the other buildings
+func (p Port) Infrastructure() Infrastructure {
+	return Infrastructure{UpgradeEpoch: p.UpgradeEpoch, StorageLevel: p.StorageLevel, Executor: p.Executor, Level: p.Level}
+}
+
+// Infrastructure returns the fields the fort shares with the other buildings
+func (f Fort) Infrastructure() Infrastructure {
+	return Infrastructure{UpgradeEpoch: f.UpgradeEpoch, StorageLevel: f.StorageLevel, Executor: f.Executor, Level: f.Level}
+}
+
+// Buildings returns all buildings of the castle keyed by their API name
+func (i Infra) Buildings() map[string]Infrastructure {
+	return map[string]Infrastructure{
+		"hq":       i.Headquarters,
+		"refinery": i.Refinery,
+		"bank":     i.Bank,
+		"port":     i.Port.Infrastructure(),
+		"fort":     i.Fort.Infrastructure(),
+	}
+}
+
+type TaskKind string
+
+const (
+	TaskUpkeep      TaskKind = "upkeep"
+	TaskUpgrade     TaskKind = "upgrade"
+	TaskBelowTarget TaskKind = "below_target"
+	TaskExecutor    TaskKind = "executor"
+)
+
+type Task struct {
+	Castle   string
+	Kind     TaskKind
+	Building string
+	Due      time.Time
+	Priority int
+	Message  string
+}
+
+type PlanOptions struct {
+	// UpkeepWindow lists castles whose upkeep expires within the window, defaults to 24 hours
+	UpkeepWindow time.Duration
+	// UpgradeWindow lists upgrades finishing within the window, defaults to 6 hours
+	UpgradeWindow time.Duration
+	// ExecutorMaxAge lists executors appointed longer ago, 0 disables the check
+	ExecutorMaxAge time.Duration
+	// TargetLevels lists buildings below the level, keyed like Infra.Buildings
+	TargetLevels map[string]int
+}
+
+type CastlePlan struct {
+	Castle   string
+	Name     string
+	Priority int
+	Tasks    []Task
+}
+
+// PlanCastles builds a prioritized to-do list for every castle that needs attention.
+// Castles without tasks are left out. The plans are sorted by their most urgent task
+func PlanCastles(castles map[string]CastleInfo, now time.Time, opts PlanOptions) []CastlePlan {
+	if opts.UpkeepWindow <= 0 {
+		opts.UpkeepWindow = 24 * time.Hour
+	}
+	if opts.UpgradeWindow <= 0 {
+		opts.UpgradeWindow = 6 * time.Hour
+	}
+
+	ret := []CastlePlan{}
+	for id, c := range castles {
+		tasks := planCastle(id, c, now, opts)
+		if len(tasks) == 0 {
+			continue
+		}
+		sort.Slice(tasks, func(i, j int) bool {
+			if tasks[i].Priority != tasks[j].Priority {
+				return tasks[i].Priority > tasks[j].Priority
+			}
+			if !tasks[i].Due.Equal(tasks[j].Due) {
+				return tasks[i].Due.Before(tasks[j].Due)
+			}
+			return tasks[i].Building < tasks[j].Building
+		})
+		ret = append(ret, CastlePlan{Castle: id, Name: c.CustomName, Priority: tasks[0].Priority, Tasks: tasks})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Priority != ret[j].Priority {
+			return ret[i].Priority > ret[j].Priority
+		}
+		return ret[i].Castle < ret[j].Castle
+	})
+	return ret
+}
+
+// urgency scales base up to twice its value the closer due is to now
+func urgency(base int, due, now time.Time, window time.Duration) int {
+	left := due.Sub(now)
+	if left <= 0 {
+		return base * 2
+	}
+	return base + int(float64(base)*(1-float64(left)/float64(window)))
+}
+
+func planCastle(id string, c CastleInfo, now time.Time, opts PlanOptions) []Task {
+	tasks := []Task{}
+	infra := c.Infrastructure
+
+	if !infra.UpkeepEpoch.IsZero() {
+		due := infra.UpkeepEpoch.Time()
+		if due.Sub(now) <= opts.UpkeepWindow {
+			t := Task{Castle: id, Kind: TaskUpkeep, Due: due, Priority: urgency(50, due, now, opts.UpkeepWindow)}
+			if due.Before(now) {
+				t.Message = fmt.Sprintf("upkeep expired %s ago", now.Sub(due).Truncate(time.Minute))
+			} else {
+				t.Message = fmt.Sprintf("upkeep expires in %s", due.Sub(now).Truncate(time.Minute))
+			}
+			if infra.AutoUpkeep {
+				t.Priority /= 5
+				t.Message += " (auto upkeep on)"
+			}
+			tasks = append(tasks, t)
+		}
+	}
+
+	for name, b := range infra.Buildings() {
+		if !b.UpgradeEpoch.IsZero() {
+			due := b.UpgradeEpoch.Time()
+			if due.After(now) && due.Sub(now) <= opts.UpgradeWindow {
+				tasks = append(tasks, Task{
+					Castle:   id,
+					Kind:     TaskUpgrade,
+					Building: name,
+					Due:      due,
+					Priority: urgency(15, due, now, opts.UpgradeWindow),
+					Message:  fmt.Sprintf("%s upgrade finishes in %s", name, due.Sub(now).Truncate(time.Minute)),
+				})
+			}
+		}
+
+		if target, ok := opts.TargetLevels[name]; ok && b.Level < target {
+			tasks = append(tasks, Task{
+				Castle:   id,
+				Kind:     TaskBelowTarget,
+				Building: name,
+				Priority: 20 + target - b.Level,
+				Message:  fmt.Sprintf("%s is level %d, target %d", name, b.Level, target),
+			})
+		}
+
+		if opts.ExecutorMaxAge > 0 && b.Executor.Name != "" && !b.Executor.EpochAppointed.IsZero() {
+			appointed := b.Executor.EpochAppointed.Time()
+			if age := now.Sub(appointed); age > opts.ExecutorMaxAge {
+				tasks = append(tasks, Task{
+					Castle:   id,
+					Kind:     TaskExecutor,
+					Building: name,
+					Due:      appointed.Add(opts.ExecutorMaxAge),
+					Priority: 10,
+					Message:  fmt.Sprintf("%s executor %s appointed %d days ago", name, b.Executor.Name, int(age.Hours()/24)),
+				})
+			}
+		}
+	}
+	return tasks
+}
@@ -110,6 +110,9 @@ type HW struct {
 // GuildTitle seems to be currently unused
 type GuildTitle interface{}
 
+// GetProfile returns the profile of the key owner.
+// The API has no known endpoint for the public profile of another player by name or PGID,
+// use GetProfiles with the keys of the players instead
 func (w WDAPI) GetProfile(apikey string) (*Profile, error) {
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(`%s/%s/player/public/my_profile?apikey=%s`, w.BaseURL, w.Version, apikey), nil)
 	if err != nil {
@@ -123,3 +126,19 @@ func (w WDAPI) GetProfile(apikey string) (*Profile, error) {
 	}
 	return &ret, nil
 }
+
+// GetProfiles fetches the profiles of many keys with at most concurrency requests in flight,
+// concurrency defaults to 4. The result has the same order as apikeys with nil for keys that failed,
+// the error reports how many failed
+func (w WDAPI) GetProfiles(apikeys []string, concurrency int) ([]*Profile, error) {
+	ret := make([]*Profile, len(apikeys))
+	err := fanOutKeys(apikeys, concurrency, func(i int, k string) error {
+		p, err := w.GetProfile(k)
+		if err != nil {
+			return err
+		}
+		ret[i] = p
+		return nil
+	})
+	return ret, err
+}
@@ -0,0 +1,270 @@
+package wdapi
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+type Primarch struct {
+	Type  string `json:"dtype"`
+	Level int    `json:"level"`
+}
+
+type PrimarchClass int
+
+const (
+	ClassUnknown PrimarchClass = iota
+	ClassFort
+	ClassTrapper
+	ClassTaunter
+	ClassDestroyer
+	ClassSieger
+)
+
+type PrimarchTier int
+
+const (
+	TierNone PrimarchTier = iota
+	TierBronze
+	TierSilver1
+	TierSilver2
+	TierGold1
+	TierGold2
+)
+
+const fortDType = "garrison"
+
+var (
+	primtiers = []string{"", "Bronze", "Silver 1", "Silver 2", "Gold 1", "Gold 2"}
+
+	primmu      sync.RWMutex
+	primclasses = map[string]PrimarchClass{
+		"rusher":    ClassTrapper,
+		"taunter":   ClassTaunter,
+		"destroyer": ClassDestroyer,
+		"sieger":    ClassSieger,
+	}
+	primnames = map[PrimarchClass]string{
+		ClassUnknown:   "Unknown",
+		ClassFort:      "Fort",
+		ClassTrapper:   "Trapper",
+		ClassTaunter:   "Taunter",
+		ClassDestroyer: "Destroyer",
+		ClassSieger:    "Sieger",
+	}
+	primdtypes = map[PrimarchClass]string{
+		ClassFort:      fortDType,
+		ClassTrapper:   "rusher",
+		ClassTaunter:   "taunter",
+		ClassDestroyer: "destroyer",
+		ClassSieger:    "sieger",
+	}
+)
+
+// RegisterPrimarchClass adds a primarch class that this version does not know about yet.
+// dtype is the name used by the API without the tier suffix, e.g. "sieger".
+// Registering an existing dtype returns the existing class
+func RegisterPrimarchClass(dtype, name string) PrimarchClass {
+	primmu.Lock()
+	defer primmu.Unlock()
+	if c, ok := primclasses[dtype]; ok {
+		return c
+	}
+	c := PrimarchClass(len(primnames))
+	primclasses[dtype] = c
+	primnames[c] = name
+	primdtypes[c] = dtype
+	return c
+}
+
+func (c PrimarchClass) String() string {
+	primmu.RLock()
+	defer primmu.RUnlock()
+	if name, ok := primnames[c]; ok {
+		return name
+	}
+	return primnames[ClassUnknown]
+}
+
+// DType returns the name of the class used by the API
+func (c PrimarchClass) DType() string {
+	primmu.RLock()
+	defer primmu.RUnlock()
+	return primdtypes[c]
+}
+
+func (c PrimarchClass) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *PrimarchClass) UnmarshalText(text []byte) error {
+	primmu.RLock()
+	defer primmu.RUnlock()
+	for k, v := range primnames {
+		if v == string(text) {
+			*c = k
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown primarch class %q", text)
+}
+
+func (t PrimarchTier) String() string {
+	if t < 0 || int(t) >= len(primtiers) {
+		return fmt.Sprintf("Tier %d", int(t))
+	}
+	return primtiers[t]
+}
+
+func (t PrimarchTier) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *PrimarchTier) UnmarshalText(text []byte) error {
+	for i, v := range primtiers {
+		if v == string(text) {
+			*t = PrimarchTier(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown primarch tier %q", text)
+}
+
+// PrimarchType is the parsed form of a dtype like "sieger5"
+type PrimarchType struct {
+	Class PrimarchClass `json:"class"`
+	Tier  PrimarchTier  `json:"tier"`
+}
+
+// ParsePrimarchType parses a dtype as returned by the API.
+// "garrison" is a fort, everything else is a class name optionally followed by a tier digit.
+// A missing tier digit means Bronze
+func ParsePrimarchType(dtype string) (PrimarchType, error) {
+	if dtype == fortDType {
+		return PrimarchType{Class: ClassFort}, nil
+	}
+	if dtype == "" {
+		return PrimarchType{}, fmt.Errorf("empty primarch type")
+	}
+	base := dtype
+	tier := TierBronze
+	if n, err := strconv.Atoi(dtype[len(dtype)-1:]); err == nil {
+		base = dtype[:len(dtype)-1]
+		tier = PrimarchTier(n)
+	}
+	if tier < TierBronze || tier > TierGold2 {
+		return PrimarchType{}, fmt.Errorf("unknown primarch tier %d in %q", int(tier), dtype)
+	}
+	primmu.RLock()
+	class, ok := primclasses[base]
+	primmu.RUnlock()
+	if !ok {
+		return PrimarchType{}, fmt.Errorf("unknown primarch class %q in %q", base, dtype)
+	}
+	return PrimarchType{Class: class, Tier: tier}, nil
+}
+
+func (t PrimarchType) IsFort() bool {
+	return t.Class == ClassFort
+}
+
+// DType encodes the type the way the API does, it is the inverse of ParsePrimarchType
+func (t PrimarchType) DType() string {
+	if t.IsFort() {
+		return fortDType
+	}
+	if t.Tier <= TierBronze {
+		return t.Class.DType()
+	}
+	return fmt.Sprintf("%s%d", t.Class.DType(), int(t.Tier))
+}
+
+func (t PrimarchType) String() string {
+	if t.IsFort() {
+		return t.Class.String()
+	}
+	return fmt.Sprintf("%s %s", t.Tier, t.Class)
+}
+
+// Compare orders types by strength. Forts are weaker than any primarch,
+// primarchs are ordered by tier first and class second.
+// It returns -1, 0 or 1
+func (t PrimarchType) Compare(o PrimarchType) int {
+	switch {
+	case t.IsFort() != o.IsFort():
+		if t.IsFort() {
+			return -1
+		}
+		return 1
+	case t.Tier != o.Tier:
+		if t.Tier < o.Tier {
+			return -1
+		}
+		return 1
+	case t.Class != o.Class:
+		if t.Class < o.Class {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// PrimarchInfo is the structured form of a Primarch
+type PrimarchInfo struct {
+	PrimarchType
+	Level int `json:"level"`
+}
+
+// Compare orders by type first and level second
+func (i PrimarchInfo) Compare(o PrimarchInfo) int {
+	if c := i.PrimarchType.Compare(o.PrimarchType); c != 0 {
+		return c
+	}
+	switch {
+	case i.Level < o.Level:
+		return -1
+	case i.Level > o.Level:
+		return 1
+	}
+	return 0
+}
+
+// Primarch converts the info back into the wire format
+func (i PrimarchInfo) Primarch() Primarch {
+	return Primarch{Type: i.DType(), Level: i.Level}
+}
+
+// ByStrength sorts from weakest to strongest
+type ByStrength []PrimarchInfo
+
+func (b ByStrength) Len() int           { return len(b) }
+func (b ByStrength) Less(i, j int) bool { return b[i].Compare(b[j]) < 0 }
+func (b ByStrength) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// Parse is ParsePrimarchType with the level attached
+func (p Primarch) Parse() (PrimarchInfo, error) {
+	t, err := ParsePrimarchType(p.Type)
+	if err != nil {
+		return PrimarchInfo{}, err
+	}
+	return PrimarchInfo{PrimarchType: t, Level: p.Level}, nil
+}
+
+// Info is like Parse but only reports whether the dtype is known
+func (p Primarch) Info() (PrimarchInfo, bool) {
+	info, err := p.Parse()
+	return info, err == nil
+}
+
+func (p Primarch) String() string {
+	info, ok := p.Info()
+	if !ok {
+		return fmt.Sprintf("Unknown: %s", p.Type)
+	}
+	if info.IsFort() {
+		return fmt.Sprintf("Fort level %d", info.Level)
+	}
+	return fmt.Sprintf("LVL %d %s", info.Level, info.PrimarchType)
+}
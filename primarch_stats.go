@@ -0,0 +1,112 @@
+package wdapi
+
+import (
+	"sort"
+	"time"
+)
+
+// PrimarchBreakdown groups battles by the primarch or fort the given team fought against.
+// For attacks by the team that is the defender's primarch, for defenses the attacker's.
+// Killed is the damage dealt to that primarch and Lost the team's own troop losses
+type PrimarchBreakdown struct {
+	Team    string
+	Since   time.Time
+	Groups  map[PrimarchInfo]*BattleCounts
+	Unknown BattleCounts
+}
+
+func NewPrimarchBreakdown(team string, since time.Time) *PrimarchBreakdown {
+	return &PrimarchBreakdown{
+		Team:   team,
+		Since:  since,
+		Groups: make(map[PrimarchInfo]*BattleCounts),
+	}
+}
+
+// Add aggregates reports that involve the team and are not older than Since
+func (b *PrimarchBreakdown) Add(reports ...Report) {
+	for _, r := range reports {
+		if !b.Since.IsZero() && r.Timestamp.Time().Before(b.Since) {
+			continue
+		}
+		var us, them BattlePrim
+		won := r.AttackerWon()
+		switch b.Team {
+		case r.Attacker.Team:
+			us, them = r.Attacker, r.Defender
+		case r.Defender.Team:
+			us, them = r.Defender, r.Attacker
+			won = !won
+		default:
+			continue
+		}
+
+		info, ok := them.Prim.Info()
+		if !ok {
+			b.Unknown.add(won, them.Troops.Lost, us.Troops.Lost, us.GloryWon, r.PercentDestroyed)
+			continue
+		}
+		c, ok := b.Groups[info]
+		if !ok {
+			c = &BattleCounts{}
+			b.Groups[info] = c
+		}
+		c.add(won, them.Troops.Lost, us.Troops.Lost, us.GloryWon, r.PercentDestroyed)
+	}
+}
+
+type PrimarchGroup struct {
+	PrimarchInfo
+	BattleCounts
+}
+
+func (b *PrimarchBreakdown) group(key func(PrimarchInfo) PrimarchInfo) []PrimarchGroup {
+	merged := make(map[PrimarchInfo]*BattleCounts)
+	for k, v := range b.Groups {
+		k = key(k)
+		c, ok := merged[k]
+		if !ok {
+			c = &BattleCounts{}
+			merged[k] = c
+		}
+		c.Battles += v.Battles
+		c.Wins += v.Wins
+		c.Killed += v.Killed
+		c.Lost += v.Lost
+		c.Glory += v.Glory
+		c.PercentDestroyed += v.PercentDestroyed
+	}
+	ret := make([]PrimarchGroup, 0, len(merged))
+	for k, v := range merged {
+		ret = append(ret, PrimarchGroup{PrimarchInfo: k, BattleCounts: *v})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Compare(ret[j].PrimarchInfo) < 0
+	})
+	return ret
+}
+
+// ByClassTier groups the battles by class and tier, ignoring the level.
+// Forts end up in a single group
+func (b *PrimarchBreakdown) ByClassTier() []PrimarchGroup {
+	return b.group(func(i PrimarchInfo) PrimarchInfo {
+		i.Level = 0
+		return i
+	})
+}
+
+// ByLevel groups the battles by class, tier and level.
+// For forts this is the troop losses per fort level
+func (b *PrimarchBreakdown) ByLevel() []PrimarchGroup {
+	return b.group(func(i PrimarchInfo) PrimarchInfo { return i })
+}
+
+// Get returns the totals for one class and tier
+func (b *PrimarchBreakdown) Get(t PrimarchType) BattleCounts {
+	for _, v := range b.ByClassTier() {
+		if v.PrimarchType == t {
+			return v.BattleCounts
+		}
+	}
+	return BattleCounts{}
+}
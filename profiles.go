@@ -0,0 +1,201 @@
+package wdapi
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// AttackRatio returns the share of won attacks between 0 and 1
+func (p Profile) AttackRatio() float64 {
+	if p.Battle.Attacks.N == 0 {
+		return 0
+	}
+	return float64(p.Battle.Attacks.Won) / float64(p.Battle.Attacks.N)
+}
+
+// TopDragonsAP returns the summed attack power of the top dragons
+func (p Profile) TopDragonsAP() int {
+	total := 0
+	for _, d := range p.TopDragons {
+		total += d.AP
+	}
+	return total
+}
+
+// ID returns the PGID, or the name for profiles without one
+func (p Profile) ID() string {
+	if p.PGID != "" {
+		return p.PGID
+	}
+	return p.Name
+}
+
+type ProfileSnapshot struct {
+	At      time.Time
+	Profile Profile
+}
+
+// ProfileStore keeps profile snapshots of many players. It is safe for concurrent use
+type ProfileStore struct {
+	mu      sync.RWMutex
+	players map[string][]ProfileSnapshot
+}
+
+func NewProfileStore() *ProfileStore {
+	return &ProfileStore{players: make(map[string][]ProfileSnapshot)}
+}
+
+func (s *ProfileStore) Add(at time.Time, p Profile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := p.ID()
+	s.players[id] = append(s.players[id], ProfileSnapshot{At: at, Profile: p})
+	h := s.players[id]
+	sort.SliceStable(h, func(i, j int) bool { return h[i].At.Before(h[j].At) })
+}
+
+// Collect fetches the profile of every key like GetProfiles and stores them.
+// Profiles of the keys that worked are stored even if some keys fail
+func (s *ProfileStore) Collect(w WDAPI, apikeys []string, concurrency int) error {
+	at := w.now()
+	profiles, err := w.GetProfiles(apikeys, concurrency)
+	for _, p := range profiles {
+		if p != nil {
+			s.Add(at, *p)
+		}
+	}
+	return err
+}
+
+// Players returns the IDs of all stored players
+func (s *ProfileStore) Players() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ret := make([]string, 0, len(s.players))
+	for id := range s.players {
+		ret = append(ret, id)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// History returns the snapshots of a player, oldest first
+func (s *ProfileStore) History(id string) []ProfileSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]ProfileSnapshot{}, s.players[id]...)
+}
+
+type ProfileStats struct {
+	ID           string
+	Name         string
+	Team         string
+	Snapshots    int
+	XP           int
+	XPPerDay     float64
+	Flames       int
+	FlamesPerDay float64
+	LastSeen     time.Time
+	Online       bool
+	// InactiveFor is the time since LastSeen, 0 while online
+	InactiveFor time.Duration
+	AttackRatio float64
+	DragonsAP   int
+	// TopDragons are the names of the top dragons in the newest snapshot
+	TopDragons []string
+	// DragonsAPChange is the change of TopDragonsAP between the first and last snapshot
+	DragonsAPChange int
+}
+
+func perDay(delta int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(delta) / d.Hours() * 24
+}
+
+// Stats computes the analytics of a player from all stored snapshots
+func (s *ProfileStore) Stats(id string, now time.Time) (ProfileStats, bool) {
+	h := s.History(id)
+	if len(h) == 0 {
+		return ProfileStats{}, false
+	}
+	first, last := h[0], h[len(h)-1]
+	p := last.Profile
+	span := last.At.Sub(first.At)
+	st := ProfileStats{
+		ID:              id,
+		Name:            p.Name,
+		Team:            p.TeamName,
+		Snapshots:       len(h),
+		XP:              p.XP,
+		XPPerDay:        perDay(p.XP-first.Profile.XP, span),
+		Flames:          p.LifetimeFlames,
+		FlamesPerDay:    perDay(p.LifetimeFlames-first.Profile.LifetimeFlames, span),
+		LastSeen:        p.Timestamps.LastSeen.Time(),
+		Online:          p.Online,
+		AttackRatio:     p.AttackRatio(),
+		DragonsAP:       p.TopDragonsAP(),
+		DragonsAPChange: p.TopDragonsAP() - first.Profile.TopDragonsAP(),
+	}
+	for _, d := range p.TopDragons {
+		st.TopDragons = append(st.TopDragons, d.Name())
+	}
+	if !p.Online && !st.LastSeen.IsZero() {
+		st.InactiveFor = now.Sub(st.LastSeen)
+	}
+	return st, true
+}
+
+// AllStats returns the stats of every stored player sorted by name
+func (s *ProfileStore) AllStats(now time.Time) []ProfileStats {
+	ret := []ProfileStats{}
+	for _, id := range s.Players() {
+		if st, ok := s.Stats(id, now); ok {
+			ret = append(ret, st)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// Inactive returns the players not seen for longer than after, longest inactive first
+func (s *ProfileStore) Inactive(now time.Time, after time.Duration) []ProfileStats {
+	ret := []ProfileStats{}
+	for _, st := range s.AllStats(now) {
+		if st.InactiveFor > after {
+			ret = append(ret, st)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].InactiveFor > ret[j].InactiveFor })
+	return ret
+}
+
+type DragonProgress struct {
+	ID string
+	// Name is the catalog name, see Dragon.Name
+	Name   string
+	Points []ScorePoint
+}
+
+// DragonProgression returns the attack power of each top dragon across the snapshots of a player
+func (s *ProfileStore) DragonProgression(id string) []DragonProgress {
+	dragons := make(map[string]*DragonProgress)
+	for _, snap := range s.History(id) {
+		for _, d := range snap.Profile.TopDragons {
+			p, ok := dragons[d.ID]
+			if !ok {
+				p = &DragonProgress{ID: d.ID, Name: d.Name()}
+				dragons[d.ID] = p
+			}
+			p.Points = append(p.Points, ScorePoint{At: snap.At, Score: d.AP})
+		}
+	}
+	ret := make([]DragonProgress, 0, len(dragons))
+	for _, v := range dragons {
+		ret = append(ret, *v)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return ret
+}
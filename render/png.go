@@ -0,0 +1,110 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/stellanera98/wdapi"
+)
+
+// Image draws the map of m. Labels and legend text are not drawn, see the package documentation
+func Image(m *wdapi.CastlesMacro, opts Options) *image.RGBA {
+	l := newLayout(m, opts)
+	o := l.opts
+	img := image.NewRGBA(image.Rect(0, 0, o.Width, o.Height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: o.Background}, image.Point{}, draw.Src)
+
+	if o.Territory != nil {
+		for _, s := range o.Territory.Ranking() {
+			for _, poly := range s.Polygons {
+				pts := make([][2]float64, len(poly))
+				for i, c := range poly {
+					pts[i][0], pts[i][1] = l.project(c)
+				}
+				fillPolygon(img, pts, ColorFor(s.Name), 0.25)
+			}
+		}
+	}
+
+	for _, p := range l.points {
+		c := p.color
+		if p.change == Removed {
+			c.R, c.G, c.B = c.R/3, c.G/3, c.B/3
+		}
+		disc(img, p.x, p.y, 0, p.r, c)
+		if p.change != "" {
+			disc(img, p.x, p.y, p.r+2, p.r+4, changeColors[p.change])
+		}
+	}
+
+	if o.Legend {
+		y := 8
+		for _, e := range l.legend {
+			draw.Draw(img, image.Rect(8, y, 18, y+10), &image.Uniform{C: e.color}, image.Point{}, draw.Over)
+			y += 14
+		}
+	}
+	return img
+}
+
+// PNG writes the map of m as PNG, see Image for the limitations
+func PNG(w io.Writer, m *wdapi.CastlesMacro, opts Options) error {
+	return png.Encode(w, Image(m, opts))
+}
+
+// fillPolygon blends c with the given opacity into the pixels inside the polygon, using the even-odd rule
+func fillPolygon(img *image.RGBA, pts [][2]float64, c color.RGBA, opacity float64) {
+	b := img.Bounds()
+	xs := []float64{}
+	for py := b.Min.Y; py < b.Max.Y; py++ {
+		y := float64(py) + 0.5
+		xs = xs[:0]
+		for i := range pts {
+			a, z := pts[i], pts[(i+1)%len(pts)]
+			if (a[1] <= y) == (z[1] <= y) {
+				continue
+			}
+			xs = append(xs, a[0]+(y-a[1])/(z[1]-a[1])*(z[0]-a[0]))
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			from := int(math.Max(math.Ceil(xs[i]-0.5), float64(b.Min.X)))
+			to := int(math.Min(math.Floor(xs[i+1]-0.5), float64(b.Max.X-1)))
+			for px := from; px <= to; px++ {
+				d := img.RGBAAt(px, py)
+				img.SetRGBA(px, py, color.RGBA{
+					R: blend(d.R, c.R, opacity),
+					G: blend(d.G, c.G, opacity),
+					B: blend(d.B, c.B, opacity),
+					A: 0xff,
+				})
+			}
+		}
+	}
+}
+
+func blend(dst, src uint8, opacity float64) uint8 {
+	return uint8(math.Round(float64(dst)*(1-opacity) + float64(src)*opacity))
+}
+
+// disc fills the ring between inner and outer radius around x, y
+func disc(img *image.RGBA, x, y, inner, outer float64, c color.RGBA) {
+	b := img.Bounds()
+	for py := int(math.Floor(y - outer)); py <= int(math.Ceil(y+outer)); py++ {
+		for px := int(math.Floor(x - outer)); px <= int(math.Ceil(x+outer)); px++ {
+			if !image.Pt(px, py).In(b) {
+				continue
+			}
+			d := math.Hypot(float64(px)+0.5-x, float64(py)+0.5-y)
+			if d > outer || d < inner {
+				continue
+			}
+			img.SetRGBA(px, py, c)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Package render draws kingdom maps from wdapi.CastlesMacro as SVG or PNG.
+//
+// PNG output is drawn with the standard library only, which has no text rendering.
+// PNG maps therefore have no castle labels and the legend only shows the color swatches
+// without names or counts. Use SVG where the text matters
+package render
+
+import (
+	"hash/fnv"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/stellanera98/wdapi"
+)
+
+type ColorBy int
+
+const (
+	ByTeam ColorBy = iota
+	ByAlliance
+)
+
+// Options control the map output. All options apply to SVG and PNG
+// except Labels and the legend text, which are only drawn in SVG
+type Options struct {
+	// Width and Height of the output in pixels, both default to 1024
+	Width  int
+	Height int
+	// ColorBy selects whether castles are colored by owner team or by the owner team's alliance.
+	// Coloring by alliance needs Alliances
+	ColorBy   ColorBy
+	Alliances *wdapi.Alliances
+	// Labels draws the castle IDs next to the castles
+	Labels bool
+	// Legend draws a legend of the colors
+	Legend bool
+	// Previous draws a diff overlay of the changes since this snapshot
+	Previous *wdapi.CastlesMacro
+	// Territory draws the territory polygons underneath the castles
+	Territory *wdapi.Territory
+	// Background defaults to a dark grey
+	Background color.Color
+}
+
+func (o Options) withDefaults() Options {
+	if o.Width <= 0 {
+		o.Width = 1024
+	}
+	if o.Height <= 0 {
+		o.Height = 1024
+	}
+	if o.Background == nil {
+		o.Background = color.RGBA{R: 0x20, G: 0x22, B: 0x25, A: 0xff}
+	}
+	return o
+}
+
+type ChangeType string
+
+const (
+	Captured     ChangeType = "captured"
+	Added        ChangeType = "added"
+	Removed      ChangeType = "removed"
+	LevelChanged ChangeType = "level"
+)
+
+var changeColors = map[ChangeType]color.RGBA{
+	Captured:     {R: 0xff, G: 0x30, B: 0x30, A: 0xff},
+	Added:        {R: 0x30, G: 0xff, B: 0x30, A: 0xff},
+	Removed:      {R: 0x90, G: 0x90, B: 0x90, A: 0xff},
+	LevelChanged: {R: 0xff, G: 0xd0, B: 0x30, A: 0xff},
+}
+
+type Change struct {
+	ID     string
+	Type   ChangeType
+	Before wdapi.Castle
+	After  wdapi.Castle
+}
+
+// Diff lists the castles that changed owner or level, appeared or disappeared between two snapshots
+func Diff(prev, next *wdapi.CastlesMacro) []Change {
+	ret := []Change{}
+	for id, now := range next.Castles {
+		was, ok := prev.Castles[id]
+		switch {
+		case !ok:
+			ret = append(ret, Change{ID: id, Type: Added, After: now})
+		case was.OwnerTeam != now.OwnerTeam:
+			ret = append(ret, Change{ID: id, Type: Captured, Before: was, After: now})
+		case was.Level != now.Level:
+			ret = append(ret, Change{ID: id, Type: LevelChanged, Before: was, After: now})
+		}
+	}
+	for id, was := range prev.Castles {
+		if _, ok := next.Castles[id]; !ok {
+			ret = append(ret, Change{ID: id, Type: Removed, Before: was})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return ret
+}
+
+// ColorFor returns a stable color for a team or alliance name. The empty name is grey
+func ColorFor(name string) color.RGBA {
+	if name == "" {
+		return color.RGBA{R: 0x70, G: 0x70, B: 0x70, A: 0xff}
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return hsv(float64(h.Sum32()%360), 0.65, 0.95)
+}
+
+func hsv(h, s, v float64) color.RGBA {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return color.RGBA{R: uint8((r + m) * 255), G: uint8((g + m) * 255), B: uint8((b + m) * 255), A: 0xff}
+}
+
+type point struct {
+	id     string
+	x, y   float64
+	r      float64
+	group  string
+	color  color.RGBA
+	change ChangeType
+}
+
+type legendEntry struct {
+	name  string
+	color color.RGBA
+	count int
+}
+
+type layout struct {
+	opts   Options
+	points []point
+	legend []legendEntry
+	// project converts raw coordinates to pixels
+	project func(wdapi.Coords) (float64, float64)
+}
+
+func newLayout(m *wdapi.CastlesMacro, opts Options) *layout {
+	opts = opts.withDefaults()
+	l := &layout{opts: opts, project: func(wdapi.Coords) (float64, float64) { return 0, 0 }}
+
+	castles := make(map[string]wdapi.Castle, len(m.Castles))
+	for id, v := range m.Castles {
+		castles[id] = v
+	}
+	changes := make(map[string]ChangeType)
+	if opts.Previous != nil {
+		for _, v := range Diff(opts.Previous, m) {
+			changes[v.ID] = v.Type
+			if v.Type == Removed {
+				castles[v.ID] = v.Before
+			}
+		}
+	}
+	if len(castles) == 0 {
+		return l
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, v := range castles {
+		x, y := v.Coords.Map()
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	pad := 24.0
+	w, h := float64(opts.Width)-2*pad, float64(opts.Height)-2*pad
+	scale := math.Min(w/math.Max(maxX-minX, 1), h/math.Max(maxY-minY, 1))
+
+	l.project = func(c wdapi.Coords) (float64, float64) {
+		x, y := c.Map()
+		return pad + (x-minX)*scale, pad + (maxY-y)*scale
+	}
+
+	alliances := opts.Alliances.Index()
+	counts := make(map[string]int)
+	for id, v := range castles {
+		x, y := l.project(v.Coords)
+		group := v.OwnerTeam
+		if opts.ColorBy == ByAlliance {
+			group, _ = alliances.AllianceOf(v.OwnerTeam)
+		}
+		counts[group]++
+		l.points = append(l.points, point{
+			id:     id,
+			x:      x,
+			y:      y,
+			r:      2 + float64(v.Level)*0.5,
+			group:  group,
+			color:  ColorFor(group),
+			change: changes[id],
+		})
+	}
+	sort.Slice(l.points, func(i, j int) bool { return l.points[i].id < l.points[j].id })
+
+	for name, n := range counts {
+		l.legend = append(l.legend, legendEntry{name: name, color: ColorFor(name), count: n})
+	}
+	sort.Slice(l.legend, func(i, j int) bool {
+		if l.legend[i].count != l.legend[j].count {
+			return l.legend[i].count > l.legend[j].count
+		}
+		return l.legend[i].name < l.legend[j].name
+	})
+	return l
+}
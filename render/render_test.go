@@ -0,0 +1,111 @@
+package render
+
+import (
+    "bytes"
+    "image/png"
+    "strings"
+    "testing"
+
+    "github.com/stellanera98/wdapi"
+)
+
+func testMaps() (prev, next *wdapi.CastlesMacro) {
+    at := func(x, y float64) wdapi.Coords { return wdapi.CoordsFromMap(x, y) }
+    prev = &wdapi.CastlesMacro{Castles: map[string]wdapi.Castle{
+        "1-A0-0": {OwnerTeam: "red", Coords: at(0, 0), Level: 1},
+        "1-A0-1": {OwnerTeam: "red", Coords: at(100, 0), Level: 1},
+        "1-A0-2": {OwnerTeam: "blue", Coords: at(0, 100), Level: 1},
+        "1-A0-3": {OwnerTeam: "blue", Coords: at(100, 100), Level: 1},
+    }}
+    next = &wdapi.CastlesMacro{Castles: map[string]wdapi.Castle{
+        "1-A0-0": {OwnerTeam: "red", Coords: at(0, 0), Level: 1},
+        "1-A0-1": {OwnerTeam: "blue", Coords: at(100, 0), Level: 1},
+        "1-A0-2": {OwnerTeam: "blue", Coords: at(0, 100), Level: 2},
+        "1-A0-4": {OwnerTeam: "<green>", Coords: at(50, 50), Level: 1},
+    }}
+    return prev, next
+}
+
+func TestDiff(t *testing.T) {
+    prev, next := testMaps()
+    want := []Change{
+        {ID: "1-A0-1", Type: Captured},
+        {ID: "1-A0-2", Type: LevelChanged},
+        {ID: "1-A0-3", Type: Removed},
+        {ID: "1-A0-4", Type: Added},
+    }
+    res := Diff(prev, next)
+    if len(res) != len(want) {
+        t.Fatalf("have '%+v' want '%+v'", res, want)
+    }
+    for i, c := range res {
+        if c.ID != want[i].ID || c.Type != want[i].Type {
+            t.Errorf("have '%+v' want '%+v'", c, want[i])
+        }
+    }
+    if res[0].Before.OwnerTeam != "red" || res[0].After.OwnerTeam != "blue" {
+        t.Errorf("have '%+v'", res[0])
+    }
+}
+
+func TestSVG(t *testing.T) {
+    prev, next := testMaps()
+    b := bytes.Buffer{}
+    if err := SVG(&b, next, Options{Width: 200, Height: 100, Labels: true, Legend: true, Previous: prev}); err != nil {
+        t.Fatal(err)
+    }
+    out := b.String()
+    for _, want := range []string{
+        `width="200" height="100"`,
+        `>1-A0-4</text>`,
+        `&lt;green&gt; (1)</text>`,
+        `blue (3)</text>`,
+        `>captured</text>`,
+        `stroke="` + hex(changeColors[Captured]) + `"`,
+        `stroke="` + hex(changeColors[Removed]) + `"`,
+        `fill-opacity="0.3"><title>1-A0-3 blue</title>`,
+    } {
+        if !strings.Contains(out, want) {
+            t.Errorf("missing '%s' in\n%s", want, out)
+        }
+    }
+    if strings.Contains(out, "<green>") {
+        t.Error("team name not escaped")
+    }
+
+    b.Reset()
+    if err := SVG(&b, next, Options{}); err != nil {
+        t.Fatal(err)
+    }
+    if out := b.String(); strings.Contains(out, "<text") || !strings.Contains(out, `width="1024" height="1024"`) {
+        t.Errorf("have unexpected output\n%s", out)
+    }
+}
+
+func TestPNG(t *testing.T) {
+    _, next := testMaps()
+    territory := wdapi.ComputeTerritory(next, wdapi.TerritoryOptions{CellSize: 10, Radius: 40})
+    opts := Options{Width: 300, Height: 200, Legend: true, Territory: territory}
+    b := bytes.Buffer{}
+    if err := PNG(&b, next, opts); err != nil {
+        t.Fatal(err)
+    }
+    img, err := png.Decode(&b)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if size := img.Bounds().Size(); size.X != 300 || size.Y != 200 {
+        t.Errorf("have '%v' want '300x200'", size)
+    }
+
+    plain := Image(next, Options{Width: 300, Height: 200})
+    filled := Image(next, opts)
+    l := newLayout(next, opts)
+    x, y := l.project(wdapi.CoordsFromMap(0, 20))
+    if plain.RGBAAt(int(x), int(y)) == filled.RGBAAt(int(x), int(y)) {
+        t.Errorf("territory not drawn at %.0f,%.0f", x, y)
+    }
+    if c := filled.RGBAAt(12, 12); c != l.legend[0].color {
+        t.Errorf("have legend swatch '%v' want '%v'", c, l.legend[0].color)
+    }
+}
@@ -0,0 +1,84 @@
+package render
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+
+	"github.com/stellanera98/wdapi"
+)
+
+func hex(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+func escape(s string) string {
+	b := strings.Builder{}
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// SVG writes the map of m as an SVG document
+func SVG(w io.Writer, m *wdapi.CastlesMacro, opts Options) error {
+	l := newLayout(m, opts)
+	o := l.opts
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", o.Width, o.Height, o.Width, o.Height)
+	fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", hex(o.Background))
+
+	if o.Territory != nil {
+		for _, s := range o.Territory.Ranking() {
+			for _, poly := range s.Polygons {
+				pts := strings.Builder{}
+				for _, c := range poly {
+					x, y := l.project(c)
+					fmt.Fprintf(&pts, "%.1f,%.1f ", x, y)
+				}
+				fmt.Fprintf(bw, `<polygon points="%s" fill="%s" fill-opacity="0.25" stroke="none"/>`+"\n", strings.TrimSpace(pts.String()), hex(ColorFor(s.Name)))
+			}
+		}
+	}
+
+	for _, p := range l.points {
+		fill := hex(p.color)
+		opacity := 1.0
+		if p.change == Removed {
+			opacity = 0.3
+		}
+		fmt.Fprintf(bw, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s" fill-opacity="%.1f"><title>%s %s</title></circle>`+"\n", p.x, p.y, p.r, fill, opacity, escape(p.id), escape(p.group))
+		if p.change != "" {
+			fmt.Fprintf(bw, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="none" stroke="%s" stroke-width="2"/>`+"\n", p.x, p.y, p.r+3, hex(changeColors[p.change]))
+		}
+		if o.Labels {
+			fmt.Fprintf(bw, `<text x="%.1f" y="%.1f" font-family="sans-serif" font-size="9" fill="#dddddd">%s</text>`+"\n", p.x+p.r+2, p.y+3, escape(p.id))
+		}
+	}
+
+	if o.Legend {
+		y := 16.0
+		for _, e := range l.legend {
+			name := e.name
+			if name == "" {
+				name = "(none)"
+			}
+			fmt.Fprintf(bw, `<rect x="8" y="%.1f" width="10" height="10" fill="%s"/>`+"\n", y-9, hex(e.color))
+			fmt.Fprintf(bw, `<text x="22" y="%.1f" font-family="sans-serif" font-size="11" fill="#ffffff">%s (%d)</text>`+"\n", y, escape(name), e.count)
+			y += 14
+		}
+		if o.Previous != nil {
+			for _, t := range []ChangeType{Captured, Added, Removed, LevelChanged} {
+				fmt.Fprintf(bw, `<circle cx="13" cy="%.1f" r="5" fill="none" stroke="%s" stroke-width="2"/>`+"\n", y-4, hex(changeColors[t]))
+				fmt.Fprintf(bw, `<text x="22" y="%.1f" font-family="sans-serif" font-size="11" fill="#ffffff">%s</text>`+"\n", y, t)
+				y += 14
+			}
+		}
+	}
+
+	fmt.Fprintln(bw, `</svg>`)
+	return bw.Flush()
+}
@@ -0,0 +1,188 @@
+package wdapi
+
+import (
+	"sort"
+	"time"
+)
+
+type RosterChangeType string
+
+const (
+	RosterJoin    RosterChangeType = "join"
+	RosterLeave   RosterChangeType = "leave"
+	RosterMove    RosterChangeType = "move"
+	RosterLevelUp RosterChangeType = "levelup"
+)
+
+// RosterSnapshot is the state of every known team roster in a kingdom at a point in time
+type RosterSnapshot struct {
+	Timestamp time.Time
+	KingdomID int
+	Teams     map[string][]Player
+}
+
+type RosterChange struct {
+	Type      RosterChangeType
+	Player    string
+	From      string
+	To        string
+	OldLevel  int
+	Level     int
+	Timestamp time.Time
+}
+
+// RosterHistory records roster snapshots of a single kingdom in chronological order
+type RosterHistory struct {
+	KingdomID int
+	Snapshots []RosterSnapshot
+}
+
+func NewRosterHistory(kingdomID int) *RosterHistory {
+	return &RosterHistory{KingdomID: kingdomID}
+}
+
+// Record adds the rosters returned by GetTeamsMetadata as a new snapshot.
+// Snapshots are kept sorted by timestamp so they can be recorded out of order
+func (h *RosterHistory) Record(ts time.Time, teams map[string]TeamMetadata) RosterSnapshot {
+	snap := RosterSnapshot{
+		Timestamp: ts,
+		KingdomID: h.KingdomID,
+		Teams:     make(map[string][]Player, len(teams)),
+	}
+	for name, v := range teams {
+		if v.TeamName != "" {
+			name = v.TeamName
+		}
+		snap.Teams[name] = append([]Player{}, v.Roster...)
+	}
+	h.Snapshots = append(h.Snapshots, snap)
+	sort.SliceStable(h.Snapshots, func(i, j int) bool {
+		return h.Snapshots[i].Timestamp.Before(h.Snapshots[j].Timestamp)
+	})
+	return snap
+}
+
+// Latest returns the most recent snapshot
+func (h RosterHistory) Latest() (RosterSnapshot, bool) {
+	if len(h.Snapshots) == 0 {
+		return RosterSnapshot{}, false
+	}
+	return h.Snapshots[len(h.Snapshots)-1], true
+}
+
+type rosterEntry struct {
+	team  string
+	level int
+}
+
+func (s RosterSnapshot) index() map[string]rosterEntry {
+	idx := make(map[string]rosterEntry)
+	for team, roster := range s.Teams {
+		for _, p := range roster {
+			idx[p.PlayerName] = rosterEntry{team: team, level: p.Level}
+		}
+	}
+	return idx
+}
+
+// DiffRosters computes the changes between two snapshots.
+// Only teams present in both snapshots are considered for joins and leaves,
+// so fetching a different set of teams does not show up as mass churn.
+// A player who left one team and shows up in another is reported as a single move
+func DiffRosters(prev, next RosterSnapshot) []RosterChange {
+	before := prev.index()
+	after := next.index()
+	changes := []RosterChange{}
+
+	tracked := func(team string) bool {
+		_, a := prev.Teams[team]
+		_, b := next.Teams[team]
+		return a && b
+	}
+
+	for name, now := range after {
+		was, ok := before[name]
+		switch {
+		case !ok:
+			if tracked(now.team) {
+				changes = append(changes, RosterChange{Type: RosterJoin, Player: name, To: now.team, Level: now.level, Timestamp: next.Timestamp})
+			}
+			continue
+		case was.team != now.team:
+			changes = append(changes, RosterChange{Type: RosterMove, Player: name, From: was.team, To: now.team, OldLevel: was.level, Level: now.level, Timestamp: next.Timestamp})
+		}
+		if now.level > was.level {
+			changes = append(changes, RosterChange{Type: RosterLevelUp, Player: name, From: was.team, To: now.team, OldLevel: was.level, Level: now.level, Timestamp: next.Timestamp})
+		}
+	}
+
+	for name, was := range before {
+		if _, ok := after[name]; ok || !tracked(was.team) {
+			continue
+		}
+		changes = append(changes, RosterChange{Type: RosterLeave, Player: name, From: was.team, OldLevel: was.level, Level: was.level, Timestamp: next.Timestamp})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Player != changes[j].Player {
+			return changes[i].Player < changes[j].Player
+		}
+		return changes[i].Type < changes[j].Type
+	})
+	return changes
+}
+
+// Changes returns every change between consecutive snapshots in chronological order.
+// A leave followed by a join of the same player into another team in a later snapshot,
+// e.g. after being teamless for a few polls, is reported as a single move at the time of the join
+func (h RosterHistory) Changes() []RosterChange {
+	all := []RosterChange{}
+	for i := 1; i < len(h.Snapshots); i++ {
+		all = append(all, DiffRosters(h.Snapshots[i-1], h.Snapshots[i])...)
+	}
+
+	dropped := make(map[int]bool)
+	left := make(map[string]int)
+	for i, v := range all {
+		leave, pending := left[v.Player]
+		delete(left, v.Player)
+		switch {
+		case v.Type == RosterLeave:
+			left[v.Player] = i
+		case v.Type == RosterJoin && pending && all[leave].From != v.To:
+			dropped[leave] = true
+			all[i] = RosterChange{Type: RosterMove, Player: v.Player, From: all[leave].From, To: v.To, OldLevel: all[leave].OldLevel, Level: v.Level, Timestamp: v.Timestamp}
+		}
+	}
+
+	ret := make([]RosterChange, 0, len(all)-len(dropped))
+	for i, v := range all {
+		if !dropped[i] {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// Trail returns all recorded changes of a single player
+func (h RosterHistory) Trail(player string) []RosterChange {
+	ret := []RosterChange{}
+	for _, v := range h.Changes() {
+		if v.Player == player {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// WhereIs answers "where did player X go".
+// It returns the team the player was last seen in and when that was.
+// If the player is on a roster in the latest snapshot the timestamp is that of the latest snapshot
+func (h RosterHistory) WhereIs(player string) (string, time.Time, bool) {
+	for i := len(h.Snapshots) - 1; i >= 0; i-- {
+		if v, ok := h.Snapshots[i].index()[player]; ok {
+			return v.team, h.Snapshots[i].Timestamp, true
+		}
+	}
+	return "", time.Time{}, false
+}
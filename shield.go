@@ -0,0 +1,154 @@
+package wdapi
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+type ShieldOptions struct {
+	// Team only reports castles owned by this team, empty reports all
+	Team string
+	// TroopBudget is the number of troops that can be lost while shielded, 0 disables budget tracking
+	TroopBudget float64
+	// BudgetWarning alerts once this share of the budget is used, defaults to 0.8
+	BudgetWarning float64
+	// GuardQuota is the number of guards a castle may hire per day, 0 disables quota tracking
+	GuardQuota int
+}
+
+type ShieldStatus struct {
+	Castle     string
+	Name       string
+	Shielded   bool
+	Since      time.Time
+	TroopsLost float64
+	// Budget is the number of troops left in the budget, only valid if BudgetTracked
+	Budget        float64
+	BudgetTracked bool
+	GuardsHired   int
+	GuardsLeft    int
+	DayIDX        int
+}
+
+// BudgetUsed returns the share of the troop budget used, 0 if there is no budget
+func (s ShieldStatus) BudgetUsed(opts ShieldOptions) float64 {
+	if opts.TroopBudget <= 0 {
+		return 0
+	}
+	return s.TroopsLost / opts.TroopBudget
+}
+
+type AlertLevel string
+
+const (
+	AlertInfo     AlertLevel = "info"
+	AlertWarning  AlertLevel = "warning"
+	AlertCritical AlertLevel = "critical"
+)
+
+type ShieldAlert struct {
+	Castle  string
+	Level   AlertLevel
+	Message string
+}
+
+type ShieldReport struct {
+	Statuses []ShieldStatus
+	Alerts   []ShieldAlert
+	// GuardsPerDay sums the guards hired by all castles per DayIDX
+	GuardsPerDay map[int]int
+}
+
+// CheckShields computes the shield status of every castle and the alerts for the defense coordinators
+func CheckShields(castles map[string]CastleInfo, opts ShieldOptions) ShieldReport {
+	if opts.BudgetWarning <= 0 {
+		opts.BudgetWarning = 0.8
+	}
+	r := ShieldReport{Statuses: []ShieldStatus{}, Alerts: []ShieldAlert{}, GuardsPerDay: make(map[int]int)}
+
+	for id, c := range castles {
+		if opts.Team != "" && c.OwnerTeam != opts.Team {
+			continue
+		}
+		f := c.Infrastructure.Fort
+		s := ShieldStatus{
+			Castle:      id,
+			Name:        c.CustomName,
+			Shielded:    f.ShieldTurnedOn,
+			Since:       f.ShieldTimeTS.Time(),
+			TroopsLost:  f.ShieldTroopsLost,
+			GuardsHired: f.GuardsHiredToday,
+			DayIDX:      f.DayIDX,
+		}
+		if opts.TroopBudget > 0 {
+			s.BudgetTracked = true
+			s.Budget = opts.TroopBudget - f.ShieldTroopsLost
+			if s.Budget < 0 {
+				s.Budget = 0
+			}
+		}
+		if opts.GuardQuota > 0 {
+			s.GuardsLeft = opts.GuardQuota - f.GuardsHiredToday
+			if s.GuardsLeft < 0 {
+				s.GuardsLeft = 0
+			}
+		}
+		r.Statuses = append(r.Statuses, s)
+		r.GuardsPerDay[f.DayIDX] += f.GuardsHiredToday
+		r.Alerts = append(r.Alerts, shieldAlerts(s, opts)...)
+	}
+
+	sort.Slice(r.Statuses, func(i, j int) bool { return r.Statuses[i].Castle < r.Statuses[j].Castle })
+	rank := map[AlertLevel]int{AlertCritical: 0, AlertWarning: 1, AlertInfo: 2}
+	sort.SliceStable(r.Alerts, func(i, j int) bool {
+		if r.Alerts[i].Level != r.Alerts[j].Level {
+			return rank[r.Alerts[i].Level] < rank[r.Alerts[j].Level]
+		}
+		return r.Alerts[i].Castle < r.Alerts[j].Castle
+	})
+	return r
+}
+
+func shieldAlerts(s ShieldStatus, opts ShieldOptions) []ShieldAlert {
+	ret := []ShieldAlert{}
+	if s.Shielded && opts.TroopBudget > 0 {
+		switch used := s.BudgetUsed(opts); {
+		case used >= 1:
+			ret = append(ret, ShieldAlert{Castle: s.Castle, Level: AlertCritical, Message: fmt.Sprintf("shield budget exhausted, %.0f troops lost", s.TroopsLost)})
+		case used >= opts.BudgetWarning:
+			ret = append(ret, ShieldAlert{Castle: s.Castle, Level: AlertWarning, Message: fmt.Sprintf("%.0f%% of shield budget used, %.0f troops left", used*100, s.Budget)})
+		}
+	}
+	if opts.GuardQuota > 0 && s.GuardsLeft == 0 {
+		ret = append(ret, ShieldAlert{Castle: s.Castle, Level: AlertInfo, Message: fmt.Sprintf("guard quota reached, %d hired today", s.GuardsHired)})
+	}
+	if !s.Shielded && !s.Since.IsZero() {
+		ret = append(ret, ShieldAlert{Castle: s.Castle, Level: AlertInfo, Message: fmt.Sprintf("shield down, last raised %s", s.Since.Format(time.RFC1123))})
+	}
+	return ret
+}
+
+// WriteTable writes the statuses as an aligned text table
+func (r ShieldReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CASTLE\tNAME\tSHIELD\tSINCE\tLOST\tBUDGET\tGUARDS\tDAY")
+	for _, s := range r.Statuses {
+		shield := "down"
+		if s.Shielded {
+			shield = "up"
+		}
+		since := "-"
+		if !s.Since.IsZero() {
+			since = s.Since.UTC().Format("2006-01-02 15:04")
+		}
+		budget := "-"
+		if s.BudgetTracked {
+			budget = fmt.Sprintf("%.0f", s.Budget)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%.0f\t%s\t%d\t%d\n", s.Castle, s.Name, shield, since, s.TroopsLost, budget, s.GuardsHired, s.DayIDX)
+	}
+	return tw.Flush()
+}
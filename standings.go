@@ -0,0 +1,203 @@
+package wdapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func (l League) String() string {
+	return fmt.Sprintf("%s/%s/%d", l.LeagueID, l.SubleagueID, l.DivisionID)
+}
+
+// LeagueOrder ranks leagues. Leagues are compared by LeagueID, then SubleagueID, then DivisionID
+type LeagueOrder struct {
+	// Leagues are the league IDs from best to worst, matched case insensitively
+	Leagues []string
+	// Subleagues are the subleague IDs from best to worst. If empty, subleagues are not ranked
+	// and only leagues and divisions decide
+	Subleagues []string
+	// HigherDivisionsFirst ranks higher division IDs above lower ones. By default division 1 is the best
+	HigherDivisionsFirst bool
+}
+
+// DefaultLeagueOrder returns the order used by NewStandings if none is given
+func DefaultLeagueOrder() LeagueOrder {
+	return LeagueOrder{
+		Leagues: []string{"master", "diamond", "platinum", "gold", "silver", "bronze"},
+	}
+}
+
+func rankOf(list []string, id string) (int, bool) {
+	for i, v := range list {
+		if strings.EqualFold(v, id) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Compare returns a negative number if a ranks above b, a positive number if b ranks above a and 0 if they are equal.
+// ok is false if a league or subleague is not part of the order
+func (o LeagueOrder) Compare(a, b League) (c int, ok bool) {
+	if a.LeagueID != b.LeagueID {
+		ra, oka := rankOf(o.Leagues, a.LeagueID)
+		rb, okb := rankOf(o.Leagues, b.LeagueID)
+		if !oka || !okb {
+			return 0, false
+		}
+		return ra - rb, true
+	}
+	if a.SubleagueID != b.SubleagueID && len(o.Subleagues) > 0 {
+		ra, oka := rankOf(o.Subleagues, a.SubleagueID)
+		rb, okb := rankOf(o.Subleagues, b.SubleagueID)
+		if !oka || !okb {
+			return 0, false
+		}
+		if ra != rb {
+			return ra - rb, true
+		}
+	}
+	if o.HigherDivisionsFirst {
+		return b.DivisionID - a.DivisionID, true
+	}
+	return a.DivisionID - b.DivisionID, true
+}
+
+type DivisionStanding struct {
+	Team      string
+	Elo       int
+	Influence int
+	// Position is the 1 based position inside the division
+	Position int
+}
+
+type Division struct {
+	League League
+	Teams  []DivisionStanding
+}
+
+// Standings groups the teams of a TeamsMacro by league, subleague and division
+type Standings struct {
+	Timestamp Epoch
+	Divisions map[League]*Division
+	Order     LeagueOrder
+	teams     map[string]League
+}
+
+// NewStandings buckets the teams by their league info and ranks them by elo, then influence.
+// order ranks the leagues for List and Moves, nil uses DefaultLeagueOrder
+func NewStandings(m *TeamsMacro, order *LeagueOrder) *Standings {
+	s := &Standings{
+		Timestamp: m.Timestamp,
+		Divisions: make(map[League]*Division),
+		Order:     DefaultLeagueOrder(),
+		teams:     make(map[string]League),
+	}
+	if order != nil {
+		s.Order = *order
+	}
+	for name, t := range m.Teams {
+		d, ok := s.Divisions[t.LeagueInfo]
+		if !ok {
+			d = &Division{League: t.LeagueInfo}
+			s.Divisions[t.LeagueInfo] = d
+		}
+		d.Teams = append(d.Teams, DivisionStanding{Team: name, Elo: t.Elo, Influence: t.Influence})
+		s.teams[name] = t.LeagueInfo
+	}
+	for _, d := range s.Divisions {
+		sort.Slice(d.Teams, func(i, j int) bool {
+			a, b := d.Teams[i], d.Teams[j]
+			if a.Elo != b.Elo {
+				return a.Elo > b.Elo
+			}
+			if a.Influence != b.Influence {
+				return a.Influence > b.Influence
+			}
+			return a.Team < b.Team
+		})
+		for i := range d.Teams {
+			d.Teams[i].Position = i + 1
+		}
+	}
+	return s
+}
+
+// Division returns the table of the division the team is in
+func (s *Standings) Division(team string) (Division, bool) {
+	l, ok := s.teams[team]
+	if !ok {
+		return Division{}, false
+	}
+	return *s.Divisions[l], true
+}
+
+// List returns all divisions from best to worst by Order.
+// Divisions the order cannot rank are sorted by their IDs after the ranked ones
+func (s *Standings) List() []Division {
+	ret := make([]Division, 0, len(s.Divisions))
+	for _, d := range s.Divisions {
+		ret = append(ret, *d)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := ret[i].League, ret[j].League
+		if c, ok := s.Order.Compare(a, b); ok && c != 0 {
+			return c < 0
+		}
+		_, oka := rankOf(s.Order.Leagues, a.LeagueID)
+		_, okb := rankOf(s.Order.Leagues, b.LeagueID)
+		if oka != okb {
+			return oka
+		}
+		if a.LeagueID != b.LeagueID {
+			return a.LeagueID < b.LeagueID
+		}
+		if a.SubleagueID != b.SubleagueID {
+			return a.SubleagueID < b.SubleagueID
+		}
+		return a.DivisionID < b.DivisionID
+	})
+	return ret
+}
+
+// League returns all divisions of one league, e.g. for Profile.PreviousGuildLeague
+func (s *Standings) League(leagueID string) []Division {
+	ret := []Division{}
+	for _, d := range s.List() {
+		if d.League.LeagueID == leagueID {
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+type LeagueMove struct {
+	Team string
+	From League
+	To   League
+	// Direction is 1 for a promotion, -1 for a relegation and 0 if the standings' Order cannot rank the leagues
+	Direction int
+}
+
+// Moves lists the teams whose league info changed since prev
+func (s *Standings) Moves(prev *Standings) []LeagueMove {
+	ret := []LeagueMove{}
+	for team, now := range s.teams {
+		was, ok := prev.teams[team]
+		if !ok || was == now {
+			continue
+		}
+		m := LeagueMove{Team: team, From: was, To: now}
+		c, _ := s.Order.Compare(now, was)
+		switch {
+		case c < 0:
+			m.Direction = 1
+		case c > 0:
+			m.Direction = -1
+		}
+		ret = append(ret, m)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Team < ret[j].Team })
+	return ret
+}
@@ -0,0 +1,87 @@
+package wdapi
+
+import (
+	"sort"
+	"time"
+)
+
+// TopDragonsCounted is how many top dragons make up the roster power
+const TopDragonsCounted = 3
+
+// PlayerSummary is a normalized view of a Profile
+type PlayerSummary struct {
+	PGID     string
+	Name     string
+	Team     string
+	GuildPos string
+	Online   bool
+	LastSeen time.Time
+	XP       int
+	Flames   int
+	// AttackRatio is the share of won attacks between 0 and 1
+	AttackRatio float64
+	// TopDragons are sorted by AP, highest first
+	TopDragons []Dragon
+	// AP is the roster power, ReportedAP if set and DragonsAP otherwise
+	AP int
+	// ReportedAP is TotalAP as sent by the API
+	ReportedAP int
+	// DragonsAP is the sum of the TopDragonsCounted strongest top dragons
+	DragonsAP int
+	// APDiscrepancy is ReportedAP - DragonsAP, the API leaves some things out of the dragon AP
+	APDiscrepancy int
+	// DP is the defense power as sent by the API, it is known to be off
+	DP int
+}
+
+// Summary reconciles the power numbers of a profile with its top dragons
+func (p Profile) Summary() PlayerSummary {
+	s := PlayerSummary{
+		PGID:        p.PGID,
+		Name:        p.Name,
+		Team:        p.TeamName,
+		GuildPos:    p.GuildPos,
+		Online:      p.Online,
+		LastSeen:    p.Timestamps.LastSeen.Time(),
+		XP:          p.XP,
+		Flames:      p.LifetimeFlames,
+		AttackRatio: p.AttackRatio(),
+		TopDragons:  append([]Dragon{}, p.TopDragons...),
+		ReportedAP:  p.TotalAP,
+		DP:          p.DP,
+	}
+	sort.SliceStable(s.TopDragons, func(i, j int) bool { return s.TopDragons[i].AP > s.TopDragons[j].AP })
+	for i, d := range s.TopDragons {
+		if i >= TopDragonsCounted {
+			break
+		}
+		s.DragonsAP += d.AP
+	}
+	s.AP = s.ReportedAP
+	if s.AP == 0 {
+		s.AP = s.DragonsAP
+	}
+	if s.ReportedAP > 0 {
+		s.APDiscrepancy = s.ReportedAP - s.DragonsAP
+	}
+	return s
+}
+
+// GetPlayerSummaries fetches the profiles of all keys like GetProfiles and summarizes them, sorted by AP, highest first.
+// Summaries of the keys that worked are returned even if some keys fail
+func (w WDAPI) GetPlayerSummaries(apikeys []string, concurrency int) ([]PlayerSummary, error) {
+	profiles, err := w.GetProfiles(apikeys, concurrency)
+	ret := []PlayerSummary{}
+	for _, p := range profiles {
+		if p != nil {
+			ret = append(ret, p.Summary())
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].AP != ret[j].AP {
+			return ret[i].AP > ret[j].AP
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret, err
+}
@@ -0,0 +1,238 @@
+package wdapi
+
+import (
+	"math"
+	"sort"
+)
+
+// Polygon is a closed ring of raw coordinates, the last point is not repeated
+type Polygon []Coords
+
+// Area returns the area in square map units
+func (p Polygon) Area() float64 {
+	a := 0.0
+	for i := range p {
+		x1, y1 := p[i].Map()
+		x2, y2 := p[(i+1)%len(p)].Map()
+		a += x1*y2 - x2*y1
+	}
+	return math.Abs(a) / 2
+}
+
+type TerritoryOptions struct {
+	// CellSize is the resolution of the influence grid in map units, defaults to 5
+	CellSize float64
+	// Radius is how far a castle's influence reaches in map units, defaults to 50
+	Radius float64
+	// ContestMargin marks a cell as contested if the runner-up has at least (1-ContestMargin)
+	// of the owner's influence. nil defaults to DefaultContestMargin, 0 only marks exact ties
+	ContestMargin *float64
+	// Group maps an owner team to the group the territory is computed for, e.g. its alliance.
+	// Defaults to the team itself
+	Group func(team string) string
+	// Teams optionally scales each castle's influence by its owner's TeamMacro.Influence,
+	// see InfluenceWeight
+	Teams *TeamsMacro
+	// InfluenceWeight is how much Teams scales castles. A castle's influence is multiplied by
+	// 1 + InfluenceWeight * (owner influence / highest influence of all teams), so castles of the
+	// most influential team count 1 + InfluenceWeight times. Defaults to 1, only used with Teams
+	InfluenceWeight float64
+}
+
+const DefaultContestMargin = 0.2
+
+type TerritoryCell struct {
+	Center    Coords
+	Owner     string
+	RunnerUp  string
+	Influence float64
+}
+
+type TerritoryShare struct {
+	Name     string
+	Cells    int
+	Area     float64
+	Share    float64
+	Polygons []Polygon
+}
+
+type Territory struct {
+	CellSize  float64
+	Cells     []TerritoryCell
+	Shares    map[string]*TerritoryShare
+	Contested []TerritoryCell
+}
+
+// ComputeTerritory assigns every cell of a grid over the kingdom to the group with the most influence there.
+// A castle's influence is its Level fading linearly to zero at Radius.
+// Unowned castles do not project influence
+func ComputeTerritory(m *CastlesMacro, opts TerritoryOptions) *Territory {
+	if opts.CellSize <= 0 {
+		opts.CellSize = 5
+	}
+	if opts.Radius <= 0 {
+		opts.Radius = 50
+	}
+	margin := DefaultContestMargin
+	if opts.ContestMargin != nil {
+		margin = *opts.ContestMargin
+	}
+	if opts.InfluenceWeight <= 0 {
+		opts.InfluenceWeight = 1
+	}
+	if opts.Group == nil {
+		opts.Group = func(team string) string { return team }
+	}
+
+	factor := map[string]float64{}
+	if opts.Teams != nil {
+		top := 0
+		for _, v := range opts.Teams.Teams {
+			if v.Influence > top {
+				top = v.Influence
+			}
+		}
+		for name, v := range opts.Teams.Teams {
+			if top > 0 {
+				factor[name] = 1 + opts.InfluenceWeight*float64(v.Influence)/float64(top)
+			}
+		}
+	}
+
+	t := &Territory{CellSize: opts.CellSize, Shares: make(map[string]*TerritoryShare)}
+	owned := make(map[string]Castle)
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for id, v := range m.Castles {
+		if v.OwnerTeam == "" {
+			continue
+		}
+		owned[id] = v
+		x, y := v.Coords.Map()
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	if len(owned) == 0 {
+		return t
+	}
+
+	idx := NewCastleIndex(owned, opts.Radius)
+	minX = math.Floor((minX-opts.Radius)/opts.CellSize) * opts.CellSize
+	minY = math.Floor((minY-opts.Radius)/opts.CellSize) * opts.CellSize
+	cols := int(math.Ceil((maxX + opts.Radius - minX) / opts.CellSize))
+	rows := int(math.Ceil((maxY + opts.Radius - minY) / opts.CellSize))
+
+	grid := make([][]string, rows)
+	total := 0
+	for row := 0; row < rows; row++ {
+		grid[row] = make([]string, cols)
+		for col := 0; col < cols; col++ {
+			center := CoordsFromMap(minX+(float64(col)+0.5)*opts.CellSize, minY+(float64(row)+0.5)*opts.CellSize)
+			influence := make(map[string]float64)
+			for _, v := range idx.Within(center, opts.Radius) {
+				w := float64(v.Castle.Level) * (1 - v.Distance/opts.Radius)
+				if f, ok := factor[v.Castle.OwnerTeam]; ok {
+					w *= f
+				}
+				influence[opts.Group(v.Castle.OwnerTeam)] += w
+			}
+			cell, ok := strongest(center, influence)
+			if !ok {
+				continue
+			}
+			grid[row][col] = cell.Owner
+			total++
+			t.Cells = append(t.Cells, cell)
+			if cell.RunnerUp != "" && influence[cell.RunnerUp] >= (1-margin)*cell.Influence {
+				t.Contested = append(t.Contested, cell)
+			}
+			s, ok := t.Shares[cell.Owner]
+			if !ok {
+				s = &TerritoryShare{Name: cell.Owner}
+				t.Shares[cell.Owner] = s
+			}
+			s.Cells++
+		}
+	}
+
+	for name, s := range t.Shares {
+		s.Area = float64(s.Cells) * opts.CellSize * opts.CellSize
+		s.Share = float64(s.Cells) / float64(total)
+		s.Polygons = gridPolygons(grid, name, minX, minY, opts.CellSize)
+	}
+	return t
+}
+
+func strongest(center Coords, influence map[string]float64) (TerritoryCell, bool) {
+	names := make([]string, 0, len(influence))
+	for k, v := range influence {
+		if v > 0 {
+			names = append(names, k)
+		}
+	}
+	if len(names) == 0 {
+		return TerritoryCell{}, false
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if influence[names[i]] != influence[names[j]] {
+			return influence[names[i]] > influence[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	cell := TerritoryCell{Center: center, Owner: names[0], Influence: influence[names[0]]}
+	if len(names) > 1 {
+		cell.RunnerUp = names[1]
+	}
+	return cell, true
+}
+
+// gridPolygons merges the cells of each row owned by name into rectangles
+func gridPolygons(grid [][]string, name string, minX, minY, size float64) []Polygon {
+	ret := []Polygon{}
+	for row := range grid {
+		for col := 0; col < len(grid[row]); col++ {
+			if grid[row][col] != name {
+				continue
+			}
+			start := col
+			for col+1 < len(grid[row]) && grid[row][col+1] == name {
+				col++
+			}
+			x1, x2 := minX+float64(start)*size, minX+float64(col+1)*size
+			y1, y2 := minY+float64(row)*size, minY+float64(row+1)*size
+			ret = append(ret, Polygon{
+				CoordsFromMap(x1, y1),
+				CoordsFromMap(x2, y1),
+				CoordsFromMap(x2, y2),
+				CoordsFromMap(x1, y2),
+			})
+		}
+	}
+	return ret
+}
+
+// Ranking returns the shares sorted by area, largest first
+func (t *Territory) Ranking() []TerritoryShare {
+	ret := make([]TerritoryShare, 0, len(t.Shares))
+	for _, v := range t.Shares {
+		ret = append(ret, *v)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Cells != ret[j].Cells {
+			return ret[i].Cells > ret[j].Cells
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// Borders returns the contested cells between two groups
+func (t *Territory) Borders(a, b string) []TerritoryCell {
+	ret := []TerritoryCell{}
+	for _, v := range t.Contested {
+		if (v.Owner == a && v.RunnerUp == b) || (v.Owner == b && v.RunnerUp == a) {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,191 @@
+package wdapi
+
+import (
+	"math"
+	"sort"
+)
+
+// TroopInventory is the combined troop count of one or many GetTroopCount calls, keyed by troop type
+type TroopInventory struct {
+	Timestamp Epoch
+	Types     map[string]TC
+}
+
+// MergeTroopCounts combines troop counts fetched with different keys of the same team.
+// Members reported by more than one key are only counted once, and so are the troops
+// not attributed to any member. Merge the counts of different teams separately
+func MergeTroopCounts(counts ...*TroopCount) *TroopInventory {
+	inv := &TroopInventory{Types: make(map[string]TC)}
+	extra := make(map[string]int)
+	for _, c := range counts {
+		if c == nil {
+			continue
+		}
+		if c.Timestamp > inv.Timestamp {
+			inv.Timestamp = c.Timestamp
+		}
+		for typ, tc := range c.TroopCount {
+			merged, ok := inv.Types[typ]
+			if !ok {
+				merged = TC{Members: make(map[string]int)}
+			}
+			sum := 0
+			for m, n := range tc.Members {
+				sum += n
+				if n > merged.Members[m] {
+					merged.Members[m] = n
+				}
+			}
+			// troops not attributed to a member, e.g. in garrisons. Every key of the team
+			// reports the same ones, so take the largest instead of adding them up
+			if rest := tc.Total - sum; rest > extra[typ] {
+				extra[typ] = rest
+			}
+			inv.Types[typ] = merged
+		}
+	}
+	for typ, tc := range inv.Types {
+		tc.Total = extra[typ]
+		for _, n := range tc.Members {
+			tc.Total += n
+		}
+		inv.Types[typ] = tc
+	}
+	return inv
+}
+
+// GetTroopInventory fetches the troop counts of many keys with at most concurrency requests in flight
+// and merges them, concurrency defaults to 4. The inventory holds the counts of the keys that worked,
+// the error reports how many failed
+func (w WDAPI) GetTroopInventory(apikeys []string, concurrency int) (*TroopInventory, error) {
+	counts := make([]*TroopCount, len(apikeys))
+	err := fanOutKeys(apikeys, concurrency, func(i int, k string) error {
+		c, err := w.GetTroopCount(k)
+		if err != nil {
+			return err
+		}
+		counts[i] = c
+		return nil
+	})
+	return MergeTroopCounts(counts...), err
+}
+
+// Total returns the number of troops of the given types, all types if none are given
+func (i *TroopInventory) Total(types ...string) int {
+	if len(types) == 0 {
+		for t := range i.Types {
+			types = append(types, t)
+		}
+	}
+	total := 0
+	for _, t := range types {
+		total += i.Types[t].Total
+	}
+	return total
+}
+
+// Member returns a member's troops of the given types, all types if none are given
+func (i *TroopInventory) Member(name string, types ...string) int {
+	if len(types) == 0 {
+		for t := range i.Types {
+			types = append(types, t)
+		}
+	}
+	total := 0
+	for _, t := range types {
+		total += i.Types[t].Members[name]
+	}
+	return total
+}
+
+type TroopUsage struct {
+	Type    string
+	Used    int
+	Members map[string]int
+}
+
+// Consumption returns the troops used since prev per type and member.
+// Positive numbers are troops used, negative numbers troops gained
+func (i *TroopInventory) Consumption(prev *TroopInventory) []TroopUsage {
+	types := make(map[string]struct{})
+	for t := range i.Types {
+		types[t] = struct{}{}
+	}
+	for t := range prev.Types {
+		types[t] = struct{}{}
+	}
+	ret := []TroopUsage{}
+	for t := range types {
+		was, now := prev.Types[t], i.Types[t]
+		u := TroopUsage{Type: t, Used: was.Total - now.Total, Members: make(map[string]int)}
+		for m, n := range was.Members {
+			u.Members[m] = n - now.Members[m]
+		}
+		for m, n := range now.Members {
+			if _, ok := was.Members[m]; !ok {
+				u.Members[m] = -n
+			}
+		}
+		ret = append(ret, u)
+	}
+	sort.Slice(ret, func(a, b int) bool { return ret[a].Type < ret[b].Type })
+	return ret
+}
+
+// SustainableAttacks returns how many attacks the inventory covers if each attack costs cost troops of the given types
+func (i *TroopInventory) SustainableAttacks(cost int, types ...string) int {
+	if cost <= 0 {
+		return 0
+	}
+	return i.Total(types...) / cost
+}
+
+// SustainableAttacksAt is SustainableAttacks with the cost per fort level taken from costs,
+// e.g. from FortAttackCosts. ok is false if there is no cost for the level
+func (i *TroopInventory) SustainableAttacksAt(level int, costs map[int]int, types ...string) (int, bool) {
+	cost, ok := costs[level]
+	if !ok {
+		return 0, false
+	}
+	return i.SustainableAttacks(cost, types...), true
+}
+
+// FortAttackCosts derives the average troops lost per attack for each fort level from a PrimarchBreakdown
+func FortAttackCosts(b *PrimarchBreakdown) map[int]int {
+	ret := make(map[int]int)
+	for _, g := range b.ByLevel() {
+		if !g.IsFort() || g.Battles == 0 {
+			continue
+		}
+		ret[g.Level] = int(math.Ceil(float64(g.Lost) / float64(g.Battles)))
+	}
+	return ret
+}
+
+type MemberStock struct {
+	Member string
+	Troops int
+}
+
+// LowStock returns the members with fewer than threshold troops of the given types, lowest first
+func (i *TroopInventory) LowStock(threshold int, types ...string) []MemberStock {
+	members := make(map[string]struct{})
+	for _, tc := range i.Types {
+		for m := range tc.Members {
+			members[m] = struct{}{}
+		}
+	}
+	ret := []MemberStock{}
+	for m := range members {
+		if n := i.Member(m, types...); n < threshold {
+			ret = append(ret, MemberStock{Member: m, Troops: n})
+		}
+	}
+	sort.Slice(ret, func(a, b int) bool {
+		if ret[a].Troops != ret[b].Troops {
+			return ret[a].Troops < ret[b].Troops
+		}
+		return ret[a].Member < ret[b].Member
+	})
+	return ret
+}
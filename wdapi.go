@@ -7,10 +7,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,31 +29,101 @@ type WDAPI struct {
 	ClientID      string
 	HTTPClient    *http.Client
 	Verbose bool
+	// Clock is used to timestamp observations of trackers, e.g. EventTracker.Poll.
+	// It defaults to time.Now and is never used for request signatures
+	Clock func() time.Time
+}
+
+func (w WDAPI) now() time.Time {
+	if w.Clock != nil {
+		return w.Clock()
+	}
+	return time.Now()
 }
 
 type APITime interface {
 	Time() time.Time
+	IsZero() bool
 	String() string
+	RelativeTo(now time.Time) string
 }
 
+// Epoch is a timestamp in seconds as sent by the API
 type Epoch float64
 
+// PGTS is a timestamp in milliseconds as sent by the API
 type PGTS float64
 
+// EpochFromTime converts t to the wire format. The zero time becomes 0
+func EpochFromTime(t time.Time) Epoch {
+	if t.IsZero() {
+		return 0
+	}
+	return Epoch(float64(t.UnixMilli()) / 1000)
+}
+
+// PGTSFromTime converts t to the wire format. The zero time becomes 0
+func PGTSFromTime(t time.Time) PGTS {
+	if t.IsZero() {
+		return 0
+	}
+	return PGTS(t.UnixMilli())
+}
+
+// Time returns the timestamp with millisecond precision, 0 is the zero time
 func (p PGTS) Time() time.Time {
-	return time.Unix(int64(p/1000), 0)
+	if p.IsZero() {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(math.Round(float64(p))))
+}
+
+// IsZero reports whether the API did not set the timestamp
+func (p PGTS) IsZero() bool {
+	return p == 0
 }
 
 func (p PGTS) String() string {
-	return fmt.Sprintf("%s ago", time.Since(time.Unix(int64(p/1000), 0)).Truncate(time.Second))
+	return p.RelativeTo(time.Now())
 }
 
+// RelativeTo is String relative to now instead of the current time
+func (p PGTS) RelativeTo(now time.Time) string {
+	return relativeTime(p, now)
+}
+
+// Time returns the timestamp with millisecond precision, 0 is the zero time
 func (e Epoch) Time() time.Time {
-	return time.Unix(int64(e), 0)
+	if e.IsZero() {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(math.Round(float64(e) * 1000)))
+}
+
+// IsZero reports whether the API did not set the timestamp
+func (e Epoch) IsZero() bool {
+	return e == 0
 }
 
 func (e Epoch) String() string {
-	return fmt.Sprintf("%s ago", time.Since(time.Unix(int64(e), 0)).Truncate(time.Second))
+	return e.RelativeTo(time.Now())
+}
+
+// RelativeTo is String relative to now instead of the current time
+func (e Epoch) RelativeTo(now time.Time) string {
+	return relativeTime(e, now)
+}
+
+// relativeTime returns "X ago" for past and "in X" for future times
+func relativeTime(t APITime, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	d := t.Time().Sub(now).Truncate(time.Second)
+	if d > 0 {
+		return fmt.Sprintf("in %s", d)
+	}
+	return fmt.Sprintf("%s ago", -d)
 }
 
 type Coords struct {
@@ -60,7 +132,8 @@ type Coords struct {
 }
 
 func (c Coords) String() string {
-	return fmt.Sprintf("X:%.1f Y:%.1f", c.X/40, c.Y/-40)
+	x, y := c.Map()
+	return fmt.Sprintf("X:%.1f Y:%.1f", x, y)
 }
 
 type PlaceID struct {
@@ -84,56 +157,102 @@ func (p PlaceID) RIDX() string {
 	return fmt.Sprintf("%s-%d", p.RegionID, p.ContIDX)
 }
 
-type Primarch struct {
-	Type  string `json:"dtype"`
-	Level int    `json:"level"`
+// ParsePlaceID parses an ID in either KRIDX ({kingdom}-{region}-{index}) or RIDX ({region}-{index}) form.
+// The kingdom can have any number of digits. For the RIDX form KingdomID is 0
+func ParsePlaceID(id string) (PlaceID, error) {
+	p, _, err := parsePlaceID(id)
+	return p, err
 }
 
-var (
-    primtiers = []string{"Bronze", "Silver 1", "Silver 2", "Gold 1", "Gold 2"}
-    primtypes = map[string]string{
-        "rusher": "Trapper",
-        "taunter": "Taunter",
-        "destroyer":"Destroyer",
-        "sieger": "Sieger",
-    }
-)
+func parsePlaceID(id string) (PlaceID, bool, error) {
+	parts := strings.Split(id, "-")
+	if len(parts) != 2 && len(parts) != 3 {
+		return PlaceID{}, false, fmt.Errorf("invalid place id %q: want {kingdom}-{region}-{index} or {region}-{index}", id)
+	}
+	p := PlaceID{}
+	hasKingdom := len(parts) == 3
+	if hasKingdom {
+		k, err := strconv.Atoi(parts[0])
+		if err != nil || k < 0 || parts[0] != strconv.Itoa(k) {
+			return PlaceID{}, false, fmt.Errorf("invalid place id %q: kingdom %q is not a number", id, parts[0])
+		}
+		p.KingdomID = k
+		parts = parts[1:]
+	}
+	if parts[0] == "" {
+		return PlaceID{}, false, fmt.Errorf("invalid place id %q: empty region", id)
+	}
+	if c := parts[0][0]; c < 'A' || (c > 'Z' && c < 'a') || c > 'z' {
+		return PlaceID{}, false, fmt.Errorf("invalid place id %q: region %q does not start with a letter", id, parts[0])
+	}
+	idx, err := strconv.Atoi(parts[1])
+	if err != nil || idx < 0 {
+		return PlaceID{}, false, fmt.Errorf("invalid place id %q: index %q is not a number", id, parts[1])
+	}
+	p.RegionID = parts[0]
+	p.ContIDX = idx
+	return p, hasKingdom, nil
+}
+
+// MarshalText encodes the ID in KRIDX form so PlaceID can be used as a JSON map key
+func (p PlaceID) MarshalText() ([]byte, error) {
+	return []byte(p.KRIDX()), nil
+}
 
-func (p Primarch) String() string {
-    if p.Type == "garrison" {
-        return fmt.Sprintf("Fort level %d", p.Level)
-    }
-    lastidx := len(p.Type)-1
-    tier, err := strconv.Atoi(string(p.Type[lastidx]))
-    if err != nil {
-        tier = 1
-        lastidx++
-    }
-    if len(primtiers) < tier {
-        return fmt.Sprintf("Unknown: %s", p.Type)
-    }
-    if _, ok := primtypes[p.Type[:lastidx]]; !ok {
-        return fmt.Sprintf("Unknown: %s", p.Type)
-    }
-    return fmt.Sprintf("LVL %d %s %s", p.Level, primtiers[tier-1], primtypes[p.Type[:lastidx]])
+func (p *PlaceID) UnmarshalText(text []byte) error {
+	parsed, err := ParsePlaceID(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+type placeID PlaceID
+
+// MarshalJSON keeps the object form the API uses
+func (p PlaceID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(placeID(p))
+}
+
+// UnmarshalJSON accepts both the object form the API uses and a KRIDX or RIDX string
+func (p *PlaceID) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		return p.UnmarshalText([]byte(s))
+	}
+	return json.Unmarshal(data, (*placeID)(p))
 }
 
 // EnsureKRIDX ensures that the ID is properly prefixed with the KID.
-// It does that by checking for the prefix "{kingdomID}-" adding it if it isnt there
+// IDs that already have a kingdom prefix are returned unchanged
 func EnsureKRIDX(id string, kingdomID int) string {
-	pref := fmt.Sprintf("%v-", kingdomID)
-	if strings.HasPrefix(id, pref) {
+	p, hasKingdom, err := parsePlaceID(id)
+	if err != nil {
+		pref := fmt.Sprintf("%v-", kingdomID)
+		if strings.HasPrefix(id, pref) {
+			return id
+		}
+		return fmt.Sprintf("%s%s", pref, id)
+	}
+	if hasKingdom {
 		return id
 	}
-	return fmt.Sprintf("%s%s", pref, id)
+	p.KingdomID = kingdomID
+	return p.KRIDX()
 }
 
-// EnsureRIDX
+// EnsureRIDX strips the kingdom prefix from an ID.
+// IDs that cannot be parsed are returned unchanged
 func EnsureRIDX(id string) string {
-    if strings.HasPrefix(id, "A") {
-        return id
-    }
-    return id[2:]
+	p, err := ParsePlaceID(id)
+	if err != nil {
+		return id
+	}
+	return p.RIDX()
 }
 
 type PGError struct {
@@ -195,6 +314,49 @@ func (w WDAPI) sendRequest(req *http.Request, res interface{}) error {
 	return nil
 }
 
+// fanOut calls fn for every index below n with at most concurrency calls in flight,
+// concurrency defaults to 4. It returns how many calls failed and the first error
+func fanOut(n, concurrency int, fn func(i int) error) (int, error) {
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	sem := make(chan struct{}, concurrency)
+	failed := 0
+	var first error
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			err := fn(i)
+			<-sem
+			if err == nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			failed++
+			if first == nil {
+				first = err
+			}
+		}(i)
+	}
+	wg.Wait()
+	return failed, first
+}
+
+// fanOutKeys is fanOut over API keys. Keys that worked are not affected by keys that failed,
+// the error reports how many failed
+func fanOutKeys(apikeys []string, concurrency int, fn func(i int, key string) error) error {
+	failed, err := fanOut(len(apikeys), concurrency, func(i int) error { return fn(i, apikeys[i]) })
+	if failed > 0 {
+		return fmt.Errorf("%d of %d keys failed: %w", failed, len(apikeys), err)
+	}
+	return nil
+}
+
 func (w WDAPI) setAuthentication(req *http.Request, key string) {
 	now := strconv.FormatInt(time.Now().Unix(), 10)
 	s := bytes.Buffer{}
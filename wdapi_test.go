@@ -1,7 +1,17 @@
 package wdapi
 
 import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "sort"
+    "strings"
+    "sync"
     "testing"
+    "time"
 )
 
 func TestEnsureKRIDX(t *testing.T) {
@@ -22,6 +32,55 @@ func TestEnsureRIDX(t *testing.T) {
     if res := EnsureRIDX("A0-1"); res != "A0-1" {
         t.Errorf("have '%s' want '%s'", res, "A0-1")
     }
+
+    if res := EnsureRIDX("12-B3-4"); res != "B3-4" {
+        t.Errorf("have '%s' want '%s'", res, "B3-4")
+    }
+
+    if res := EnsureRIDX("5"); res != "5" {
+        t.Errorf("have '%s' want '%s'", res, "5")
+    }
+}
+
+func TestParsePlaceID(t *testing.T) {
+    cases := map[string]PlaceID{
+        "5-A0-0":    {KingdomID: 5, RegionID: "A0", ContIDX: 0},
+        "123-B12-7": {KingdomID: 123, RegionID: "B12", ContIDX: 7},
+        "A0-3":      {RegionID: "A0", ContIDX: 3},
+    }
+    for in, want := range cases {
+        if res, err := ParsePlaceID(in); err != nil || res != want {
+            t.Errorf("have '%+v' (%v) want '%+v'", res, err, want)
+        }
+    }
+    for _, in := range []string{"", "5", "5-A0", "x-A0-1", "5-0-1", "5-A0-x", "1-2-3-4", "-5-A0-1"} {
+        if _, err := ParsePlaceID(in); err == nil {
+            t.Errorf("have no error for '%s'", in)
+        }
+    }
+
+    if res := EnsureKRIDX("15-A0-0", 5); res != "15-A0-0" {
+        t.Errorf("have '%s' want '%s'", res, "15-A0-0")
+    }
+
+    m := map[PlaceID]int{{KingdomID: 5, RegionID: "A0", ContIDX: 1}: 3}
+    out, err := json.Marshal(m)
+    if err != nil || string(out) != `{"5-A0-1":3}` {
+        t.Errorf("have '%s' (%v) want '%s'", out, err, `{"5-A0-1":3}`)
+    }
+    back := map[PlaceID]int{}
+    if err := json.Unmarshal(out, &back); err != nil || back[PlaceID{KingdomID: 5, RegionID: "A0", ContIDX: 1}] != 3 {
+        t.Errorf("have '%+v' (%v)", back, err)
+    }
+
+    var ci CastleInfo
+    if err := json.Unmarshal([]byte(`{"place_id":{"k_id":5,"cont_idx":2,"region_id":"A1"}}`), &ci); err != nil || ci.PlaceID.KRIDX() != "5-A1-2" {
+        t.Errorf("have '%s' (%v) want '%s'", ci.PlaceID, err, "5-A1-2")
+    }
+    out, _ = json.Marshal(ci.PlaceID)
+    if string(out) != `{"k_id":5,"cont_idx":2,"region_id":"A1"}` {
+        t.Errorf("have '%s'", out)
+    }
 }
 
 func TestPrimarchString(t *testing.T) {
@@ -46,3 +105,1104 @@ func TestPrimarchString(t *testing.T) {
         t.Errorf("have '%s' want '%s'", unknown, "Unknown: test9")
     }
 }
+
+func TestDiffRosters(t *testing.T) {
+    prev := RosterSnapshot{Teams: map[string][]Player{
+        "A": {{PlayerName: "alice", Level: 100}, {PlayerName: "bob", Level: 90}},
+        "B": {{PlayerName: "carol", Level: 50}},
+    }}
+    next := RosterSnapshot{Timestamp: time.Unix(100, 0), Teams: map[string][]Player{
+        "A": {{PlayerName: "alice", Level: 101}, {PlayerName: "dave", Level: 10}},
+        "B": {{PlayerName: "carol", Level: 50}, {PlayerName: "bob", Level: 90}},
+    }}
+
+    changes := DiffRosters(prev, next)
+    want := []RosterChange{
+        {Type: RosterLevelUp, Player: "alice", From: "A", To: "A", OldLevel: 100, Level: 101},
+        {Type: RosterMove, Player: "bob", From: "A", To: "B", OldLevel: 90, Level: 90},
+        {Type: RosterJoin, Player: "dave", To: "A", Level: 10},
+    }
+    if len(changes) != len(want) {
+        t.Fatalf("have %d changes want %d: %+v", len(changes), len(want), changes)
+    }
+    for i, v := range want {
+        v.Timestamp = next.Timestamp
+        if changes[i] != v {
+            t.Errorf("have '%+v' want '%+v'", changes[i], v)
+        }
+    }
+
+    h := NewRosterHistory(5)
+    h.Record(time.Unix(0, 0), map[string]TeamMetadata{"A": {TeamName: "A", Roster: prev.Teams["A"]}, "B": {TeamName: "B", Roster: prev.Teams["B"]}})
+    h.Record(time.Unix(100, 0), map[string]TeamMetadata{"A": {TeamName: "A", Roster: next.Teams["A"]}, "B": {TeamName: "B", Roster: next.Teams["B"]}})
+    if team, _, ok := h.WhereIs("bob"); !ok || team != "B" {
+        t.Errorf("have '%s' want '%s'", team, "B")
+    }
+
+    // erin leaves A, is teamless for one poll and then joins B
+    gap := NewRosterHistory(5)
+    roster := func(a, b []Player) map[string]TeamMetadata {
+        return map[string]TeamMetadata{"A": {TeamName: "A", Roster: a}, "B": {TeamName: "B", Roster: b}}
+    }
+    erin, frank := Player{PlayerName: "erin", Level: 60}, Player{PlayerName: "frank", Level: 30}
+    gap.Record(time.Unix(0, 0), roster([]Player{erin, frank}, nil))
+    gap.Record(time.Unix(100, 0), roster(nil, nil))
+    gap.Record(time.Unix(200, 0), roster([]Player{frank}, []Player{{PlayerName: "erin", Level: 61}}))
+
+    trail := gap.Trail("erin")
+    want = []RosterChange{{Type: RosterMove, Player: "erin", From: "A", To: "B", OldLevel: 60, Level: 61, Timestamp: time.Unix(200, 0)}}
+    if len(trail) != 1 || trail[0] != want[0] {
+        t.Errorf("have '%+v' want '%+v'", trail, want)
+    }
+    // frank rejoined his own team, which is not a move
+    if trail := gap.Trail("frank"); len(trail) != 2 || trail[0].Type != RosterLeave || trail[1].Type != RosterJoin {
+        t.Errorf("have '%+v'", trail)
+    }
+}
+
+func TestPrimarchInfo(t *testing.T) {
+    info, ok := Primarch{Type: "sieger5", Level: 25}.Info()
+    want := PrimarchInfo{PrimarchType: PrimarchType{Class: ClassSieger, Tier: TierGold2}, Level: 25}
+    if !ok || info != want {
+        t.Errorf("have '%+v' want '%+v'", info, want)
+    }
+
+    if _, ok := (Primarch{Type: "sieger0"}).Info(); ok {
+        t.Errorf("have ok for '%s' want not ok", "sieger0")
+    }
+}
+
+func TestPrimarchTypeRoundTrip(t *testing.T) {
+    for _, v := range []string{"garrison", "rusher", "taunter2", "destroyer4", "sieger5"} {
+        pt, err := ParsePrimarchType(v)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if pt.DType() != v {
+            t.Errorf("have '%s' want '%s'", pt.DType(), v)
+        }
+    }
+
+    if _, err := ParsePrimarchType("flyer3"); err == nil {
+        t.Errorf("have no error for '%s'", "flyer3")
+    }
+    c := RegisterPrimarchClass("flyer", "Flyer")
+    // remove the class again so later tests see the built-in registry only
+    t.Cleanup(func() {
+        primmu.Lock()
+        defer primmu.Unlock()
+        delete(primclasses, "flyer")
+        delete(primnames, c)
+        delete(primdtypes, c)
+    })
+    if res := (Primarch{Type: "flyer3", Level: 4}).String(); res != "LVL 4 Silver 2 Flyer" {
+        t.Errorf("have '%s' want '%s'", res, "LVL 4 Silver 2 Flyer")
+    }
+    if again := RegisterPrimarchClass("flyer", "Flyer"); again != c {
+        t.Errorf("have '%d' want '%d'", again, c)
+    }
+
+    out, err := json.Marshal(PrimarchInfo{PrimarchType: PrimarchType{Class: ClassSieger, Tier: TierGold2}, Level: 25})
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := `{"class":"Sieger","tier":"Gold 2","level":25}`
+    if string(out) != want {
+        t.Errorf("have '%s' want '%s'", out, want)
+    }
+    var info PrimarchInfo
+    if err := json.Unmarshal(out, &info); err != nil || info.DType() != "sieger5" {
+        t.Errorf("have '%+v' (%v) want '%s'", info, err, "sieger5")
+    }
+
+    infos := []PrimarchInfo{
+        {PrimarchType: PrimarchType{Class: ClassSieger, Tier: TierGold2}, Level: 1},
+        {PrimarchType: PrimarchType{Class: ClassFort}, Level: 30},
+        {PrimarchType: PrimarchType{Class: ClassTrapper, Tier: TierBronze}, Level: 50},
+    }
+    sort.Sort(ByStrength(infos))
+    if infos[0].Class != ClassFort || infos[2].Class != ClassSieger {
+        t.Errorf("have '%+v' not sorted by strength", infos)
+    }
+}
+
+func TestAPITime(t *testing.T) {
+    now := time.Unix(1700000000, 0)
+
+    if res := PGTS(1700000000999).Time(); !res.Equal(time.UnixMilli(1700000000999)) {
+        t.Errorf("have '%s' want '%s'", res, time.UnixMilli(1700000000999))
+    }
+    if res := Epoch(1700000000.25).Time(); !res.Equal(time.UnixMilli(1700000000250)) {
+        t.Errorf("have '%s' want '%s'", res, time.UnixMilli(1700000000250))
+    }
+
+    cases := map[APITime]string{
+        Epoch(0):                 "never",
+        PGTS(0):                  "never",
+        Epoch(1700000000 - 3661): "1h1m1s ago",
+        Epoch(1700000000 + 90):   "in 1m30s",
+        PGTS(1700000000000 + 1500): "in 1s",
+    }
+    for v, want := range cases {
+        if res := v.RelativeTo(now); res != want {
+            t.Errorf("have '%s' want '%s'", res, want)
+        }
+    }
+    if res := Epoch(0).Localize(German); res != "nie" {
+        t.Errorf("have '%s' want '%s'", res, "nie")
+    }
+
+    if res := EpochFromTime(time.UnixMilli(1700000000250)); res != 1700000000.25 {
+        t.Errorf("have '%v' want '%v'", res, 1700000000.25)
+    }
+    out, err := json.Marshal(Buff{Amount: 1, TS: EpochFromTime(now)})
+    if err != nil || string(out) != `{"amount":1,"ts":1700000000}` {
+        t.Errorf("have '%s' want '%s'", out, `{"amount":1,"ts":1700000000}`)
+    }
+    if res := PGTSFromTime(time.Time{}); !res.IsZero() {
+        t.Errorf("have '%v' want '%v'", res, 0)
+    }
+}
+
+func TestCastleIndex(t *testing.T) {
+    castles := map[string]Castle{}
+    for x := 0; x < 20; x++ {
+        for y := 0; y < 20; y++ {
+            castles[fmt.Sprintf("5-A0-%d", x*20+y)] = Castle{Coords: CoordsFromMap(float64(x*7), float64(y*7))}
+        }
+    }
+    idx := NewCastleIndex(castles, 5)
+
+    for _, center := range []Coords{CoordsFromMap(30, 31), CoordsFromMap(-50, 200), CoordsFromMap(133, 0.5)} {
+        want := CastlesWithin(castles, center, 1000)[0]
+        have, ok := idx.Nearest(center, nil)
+        if !ok || have.ID != want.ID {
+            t.Errorf("have '%s' want '%s'", have.ID, want.ID)
+        }
+        if len(idx.Within(center, 20)) != len(CastlesWithin(castles, center, 20)) {
+            t.Errorf("have %d castles want %d", len(idx.Within(center, 20)), len(CastlesWithin(castles, center, 20)))
+        }
+    }
+    if res := idx.Within(CoordsFromMap(0, 0), math.Inf(1)); len(res) != len(castles) {
+        t.Errorf("have %d castles want %d", len(res), len(castles))
+    }
+    if res := NewCastleIndex(nil, 5).Within(CoordsFromMap(0, 0), 1e12); len(res) != 0 {
+        t.Errorf("have '%+v' want none", res)
+    }
+
+    if res := CoordsFromMap(0, 0).Bearing(CoordsFromMap(1, 0)); res != 90 {
+        t.Errorf("have '%v' want '%v'", res, 90)
+    }
+    if res := CoordsFromMap(0, 0).Distance(CoordsFromMap(3, 4)); res != 5 {
+        t.Errorf("have '%v' want '%v'", res, 5)
+    }
+}
+
+func TestComputeTerritory(t *testing.T) {
+    m := &CastlesMacro{Castles: map[string]Castle{
+        "1-A0-0": {OwnerTeam: "A", Level: 10, Coords: CoordsFromMap(0, 0)},
+        "1-A0-1": {OwnerTeam: "B", Level: 10, Coords: CoordsFromMap(60, 0)},
+        "1-A0-2": {Level: 30, Coords: CoordsFromMap(30, 30)},
+    }}
+    margin := 0.3
+    terr := ComputeTerritory(m, TerritoryOptions{CellSize: 5, Radius: 50, ContestMargin: &margin})
+
+    a, b := terr.Shares["A"], terr.Shares["B"]
+    if a == nil || b == nil || a.Cells != b.Cells {
+        t.Fatalf("have '%+v' '%+v' want equal shares", a, b)
+    }
+    if len(terr.Borders("A", "B")) == 0 {
+        t.Errorf("have no contested border between '%s' and '%s'", "A", "B")
+    }
+    area := 0.0
+    for _, p := range a.Polygons {
+        area += p.Area()
+    }
+    if area != a.Area {
+        t.Errorf("have '%v' want '%v'", area, a.Area)
+    }
+
+    exact := 0.0
+    if res := ComputeTerritory(m, TerritoryOptions{CellSize: 5, Radius: 50, ContestMargin: &exact}); len(res.Contested) != 0 {
+        t.Errorf("have '%d' contested cells without ties", len(res.Contested))
+    }
+    if res := ComputeTerritory(m, TerritoryOptions{CellSize: 5, Radius: 50}); len(res.Contested) > len(terr.Contested) {
+        t.Errorf("have '%d' contested cells with the default margin, '%d' with '%v'", len(res.Contested), len(terr.Contested), margin)
+    }
+
+    teams := &TeamsMacro{Teams: map[string]TeamMacro{"A": {Influence: 100}, "B": {Influence: 0}}}
+    weighted := ComputeTerritory(m, TerritoryOptions{CellSize: 5, Radius: 50, Teams: teams})
+    heavy := ComputeTerritory(m, TerritoryOptions{CellSize: 5, Radius: 50, Teams: teams, InfluenceWeight: 3})
+    if !(weighted.Shares["A"].Cells > a.Cells && heavy.Shares["A"].Cells > weighted.Shares["A"].Cells) {
+        t.Errorf("have '%d' '%d' '%d' cells for A", a.Cells, weighted.Shares["A"].Cells, heavy.Shares["A"].Cells)
+    }
+}
+
+func TestLeaderboard(t *testing.T) {
+    teams := &TeamsMacro{Teams: map[string]TeamMacro{
+        "A": {Elo: 1500, Rank: 2, Influence: 100},
+        "B": {Elo: 1600, Rank: 1, Influence: 50},
+        "C": {Elo: 1000, Rank: 3, Influence: 10},
+    }}
+    kills := map[string]TeamKills{"A": {TotalKills: 1000}, "B": {TotalKills: 500}}
+    castles := &CastlesMacro{Castles: map[string]Castle{"1-A0-0": {OwnerTeam: "A"}, "1-A0-1": {OwnerTeam: "A"}, "1-A0-2": {OwnerTeam: "B"}}}
+
+    l := BuildLeaderboard(teams, kills, castles, nil, nil)
+    if l.Standings[0].Team != "A" || l.Standings[2].Team != "C" {
+        t.Errorf("have '%+v' want A first and C last", l.Standings)
+    }
+    if res := l.Ranked(MetricRank)[0].Team; res != "B" {
+        t.Errorf("have '%s' want '%s'", res, "B")
+    }
+
+    prev := BuildLeaderboard(teams, nil, nil, nil, map[Metric]float64{MetricElo: 1})
+    for _, v := range l.Deltas(prev, MetricScore) {
+        if v.Team == "A" && v.Change != 1 {
+            t.Errorf("have '%d' want '%d'", v.Change, 1)
+        }
+    }
+}
+
+func TestAllianceIndex(t *testing.T) {
+    a := &Alliances{Alliances: []map[string][]string{{"X": {"A", "B"}}, {"Y": {"C"}}}}
+    idx := a.Index()
+
+    if res, ok := idx.AllianceOf("B"); !ok || res != "X" {
+        t.Errorf("have '%s' want '%s'", res, "X")
+    }
+    if res := idx.TeamsOf("X"); len(res) != 2 || res[0] != "A" {
+        t.Errorf("have '%v' want '%v'", res, []string{"A", "B"})
+    }
+
+    mismatches := idx.CrossCheck(map[string]TeamMetadata{"A": {TeamName: "A", Alliance: "X"}, "C": {TeamName: "C", Alliance: "Z"}})
+    if len(mismatches) != 1 || mismatches[0] != (AllianceMismatch{Team: "C", Index: "Y", Metadata: "Z"}) {
+        t.Errorf("have '%+v'", mismatches)
+    }
+
+    stats := idx.Stats(&CastlesMacro{Castles: map[string]Castle{"1-A0-0": {OwnerTeam: "A"}, "1-A0-1": {OwnerTeam: "B"}}}, map[string]TeamKills{"C": {TotalKills: 7}}, nil)
+    if stats[0].Castles != 2 || stats[1].TotalKills != 7 {
+        t.Errorf("have '%+v'", stats)
+    }
+
+    if res := (*Alliances)(nil).List(); len(res) != 0 {
+        t.Errorf("have '%+v' want none", res)
+    }
+}
+
+func TestPlanCastles(t *testing.T) {
+    now := time.Unix(1700000000, 0)
+    castles := map[string]CastleInfo{
+        "1-A0-0": {Infrastructure: Infra{UpkeepEpoch: EpochFromTime(now.Add(time.Hour)), Bank: Infrastructure{Level: 3}}},
+        "1-A0-1": {Infrastructure: Infra{UpkeepEpoch: EpochFromTime(now.Add(-time.Hour)), Bank: Infrastructure{Level: 5}, Fort: Fort{Executor: Executor{Name: "x", EpochAppointed: EpochFromTime(now.Add(-10 * 24 * time.Hour))}}}},
+        "1-A0-2": {Infrastructure: Infra{UpkeepEpoch: EpochFromTime(now.Add(48 * time.Hour)), Bank: Infrastructure{Level: 5}}},
+    }
+    plans := PlanCastles(castles, now, PlanOptions{TargetLevels: map[string]int{"bank": 5}, ExecutorMaxAge: 7 * 24 * time.Hour})
+
+    if len(plans) != 2 || plans[0].Castle != "1-A0-1" || plans[1].Castle != "1-A0-0" {
+        t.Fatalf("have '%+v'", plans)
+    }
+    if len(plans[0].Tasks) != 2 || plans[0].Tasks[1].Kind != TaskExecutor {
+        t.Errorf("have '%+v'", plans[0].Tasks)
+    }
+    if len(plans[1].Tasks) != 2 || plans[1].Tasks[1].Kind != TaskBelowTarget {
+        t.Errorf("have '%+v'", plans[1].Tasks)
+    }
+}
+
+func TestFleetTracker(t *testing.T) {
+    start := time.Unix(1700000000, 0)
+    poll := func(progress int) map[string]CastleInfo {
+        return map[string]CastleInfo{"1-A0-0": {Fleets: map[string]Prim{"f": {PrimType: "sieger5", SummonEpoch: 1, TauntProgress: progress, TauntThreshold: 100}}}}
+    }
+    ft := NewFleetTracker()
+    if ev := ft.Observe(start, poll(10)); len(ev) != 1 || ev[0].Type != FleetSummoned {
+        t.Errorf("have '%+v'", ev)
+    }
+    ft.Observe(start.Add(time.Hour), poll(30))
+
+    next := ft.Next()
+    if len(next) != 1 || !next[0].ETA.Equal(start.Add(4*time.Hour+30*time.Minute)) || next[0].Percent != 30 {
+        t.Errorf("have '%+v'", next)
+    }
+    if ev := ft.Observe(start.Add(2*time.Hour), map[string]CastleInfo{"1-A0-0": {}}); len(ev) != 1 || ev[0].Type != FleetGone {
+        t.Errorf("have '%+v'", ev)
+    }
+    if ev := ft.Observe(start.Add(3*time.Hour), map[string]CastleInfo{"1-A0-0": {}}); len(ev) != 0 || len(ft.Next()) != 0 {
+        t.Errorf("have '%+v'", ev)
+    }
+}
+
+func TestPrimBuffs(t *testing.T) {
+    // testdata/castle_info.json is a synthetic fixture written after the CastleInfo structs,
+    // not a captured API response
+    data, err := os.ReadFile("testdata/castle_info.json")
+    if err != nil {
+        t.Fatal(err)
+    }
+    castles := map[string]CastleInfo{}
+    if err := json.Unmarshal(data, &castles); err != nil {
+        t.Fatal(err)
+    }
+    p := castles["5-A0-0"].Fleets["0"]
+    if p.PrimType != "sieger5" || len(p.PrimarchBuffs) != 2 || p.PrimarchBuffs["A"].Attack.Amount != 5 {
+        t.Fatalf("have '%+v'", p)
+    }
+
+    now := time.Unix(1700000000+3650, 0)
+    if res := p.TeamBuff("A", now, time.Hour); res.Attack != 0 || res.Defend != 2 {
+        t.Errorf("have '%+v'", res)
+    }
+    if res := p.TeamBuff("A", now, 0); res.Attack != 5 || res.Defend != 2 {
+        t.Errorf("have '%+v'", res)
+    }
+    if res := p.TeamBuffs(now, 0); len(res) != 2 || res[0].Team != "A" || res[1].Attack != 3 {
+        t.Errorf("have '%+v'", res)
+    }
+    if res := p.TeamBuffs(now, time.Hour); res[0].Attack != 0 || res[1].Attack != 0 || res[0].Defend != 2 {
+        t.Errorf("have '%+v'", res)
+    }
+    if res, ok := p.LastBuffRefresh("A"); !ok || !res.Equal(time.Unix(1700000100, 0)) {
+        t.Errorf("have '%s' want '%s'", res, time.Unix(1700000100, 0))
+    }
+}
+
+func TestContributionReport(t *testing.T) {
+    prev := &Contribution{Timestamp: 1, Entries: []Entry{
+        {Playername: "a", Stats: Details{MonthlyGold: 100, MonthlyTroops: 10, LifetimeTroops: 1000}},
+        {Playername: "b", Stats: Details{MonthlyGold: 500, MonthlyTroops: 50, LifetimeTroops: 500}},
+    }}
+    next := &Contribution{Timestamp: 2, Entries: []Entry{
+        {Playername: "a", Stats: Details{MonthlyGold: 300, MonthlyTroops: 40, LifetimeTroops: 1030}},
+        {Playername: "b", Stats: Details{MonthlyGold: 20, MonthlyTroops: 5, LifetimeTroops: 505}},
+    }}
+
+    deltas := DiffContributions(prev, next)
+    if deltas[0].Gold != 200 || deltas[1].Gold != 20 || deltas[1].Troops != 5 {
+        t.Errorf("have '%+v'", deltas)
+    }
+    if below := (ContributionThresholds{Troops: 10}).Below(deltas); len(below) != 1 || below[0].Player != "b" {
+        t.Errorf("have '%+v'", below)
+    }
+
+    r := NewContributionReport("<week>", prev, next, ContributionThresholds{Troops: 10})
+    out := strings.Builder{}
+    if err := r.WriteCSV(&out); err != nil || !strings.HasPrefix(out.String(), "Player,Gold,Mats,Troops,Lifetime Troops,Below Threshold\na,200,0,30,30,false\n") {
+        t.Errorf("have '%s' (%v)", out.String(), err)
+    }
+    out.Reset()
+    if err := r.WriteHTML(&out); err != nil || !strings.Contains(out.String(), "&lt;week&gt;") {
+        t.Errorf("have '%s' (%v)", out.String(), err)
+    }
+
+    endOfMonth := &Contribution{Timestamp: EpochFromTime(time.Date(2023, 10, 31, 20, 0, 0, 0, time.UTC)), Entries: []Entry{
+        {Playername: "a", Stats: Details{MonthlyGold: 100, MonthlyTroops: 10}},
+    }}
+    nextMonth := &Contribution{Timestamp: EpochFromTime(time.Date(2023, 11, 6, 20, 0, 0, 0, time.UTC)), Entries: []Entry{
+        {Playername: "a", Stats: Details{MonthlyGold: 150, MonthlyTroops: 5}},
+    }}
+    if res := DiffContributions(endOfMonth, nextMonth); res[0].Gold != 150 || res[0].Troops != 5 {
+        t.Errorf("have '%+v' want gold '%v' troops '%v'", res[0], 150, 5)
+    }
+    sameMonth := &Contribution{Timestamp: EpochFromTime(time.Date(2023, 11, 13, 20, 0, 0, 0, time.UTC)), Entries: []Entry{
+        {Playername: "a", Stats: Details{MonthlyGold: 400, MonthlyTroops: 25}},
+    }}
+    if res := DiffContributions(nextMonth, sameMonth); res[0].Gold != 250 || res[0].Troops != 20 {
+        t.Errorf("have '%+v' want gold '%v' troops '%v'", res[0], 250, 20)
+    }
+}
+
+func TestTroopInventory(t *testing.T) {
+    a := &TroopCount{TroopCount: map[string]TC{"t1": {Total: 110, Members: map[string]int{"x": 60, "y": 40}}}}
+    b := &TroopCount{TroopCount: map[string]TC{"t1": {Total: 100, Members: map[string]int{"x": 60, "y": 40}}, "t2": {Total: 5, Members: map[string]int{"z": 5}}}}
+    inv := MergeTroopCounts(a, b)
+
+    if res := inv.Total("t1"); res != 110 {
+        t.Errorf("have '%d' want '%d'", res, 110)
+    }
+    if res := inv.SustainableAttacks(30, "t1"); res != 3 {
+        t.Errorf("have '%d' want '%d'", res, 3)
+    }
+    if res := inv.LowStock(50); len(res) != 2 || res[0].Member != "z" {
+        t.Errorf("have '%+v'", res)
+    }
+    if res := MergeTroopCounts(a, a).Total("t1"); res != 110 {
+        t.Errorf("have '%d' want '%d' for the same team merged twice", res, 110)
+    }
+    c := &TroopCount{TroopCount: map[string]TC{"t1": {Total: 95, Members: map[string]int{"x": 60, "w": 20}}}}
+    if res := MergeTroopCounts(a, c).Total("t1"); res != 135 {
+        t.Errorf("have '%d' want '%d'", res, 135)
+    }
+
+    later := MergeTroopCounts(&TroopCount{TroopCount: map[string]TC{"t1": {Total: 70, Members: map[string]int{"x": 30, "y": 40}}}})
+    usage := later.Consumption(inv)
+    if usage[0].Used != 40 || usage[0].Members["x"] != 30 || usage[1].Used != 5 {
+        t.Errorf("have '%+v'", usage)
+    }
+
+    w, _ := testServer(t, map[string]func(*http.Request) interface{}{
+        "/v1/atlas/team/troop_count": func(r *http.Request) interface{} {
+            switch r.Header.Get("X-WarDragons-APIKey") {
+            case "a":
+                return a
+            case "c":
+                return c
+            }
+            return http.StatusInternalServerError
+        },
+    })
+    fetched, err := w.GetTroopInventory([]string{"a", "bad", "c"}, 2)
+    if err == nil || !strings.HasPrefix(err.Error(), "1 of 3 keys failed") {
+        t.Errorf("have '%v'", err)
+    }
+    if fetched == nil || fetched.Total("t1") != 135 || fetched.Member("w") != 20 {
+        t.Errorf("have '%+v'", fetched)
+    }
+}
+
+func TestEventTracker(t *testing.T) {
+    start := time.Unix(1700000000, 0)
+    ev := EventDetails{StartEpoch: EpochFromTime(start), Type: "atlas"}
+    et := NewEventTracker(10 * time.Hour)
+    et.Record(start.Add(time.Hour), []AtlasEvent{{Score: 100, PlayerName: "a", TeamName: "T", EventDetails: ev}, {Score: 50, PlayerName: "b", TeamName: "T", EventDetails: ev}})
+    et.Record(start.Add(2*time.Hour), []AtlasEvent{{Score: 300, PlayerName: "a", TeamName: "T", EventDetails: ev}})
+
+    players := et.Players(ev.Key())
+    if players[0].Player != "a" || players[0].Projected != 300+200*8 || players[1].Projected != 500 {
+        t.Errorf("have '%+v'", players)
+    }
+    if teams := et.Teams(ev.Key()); len(teams) != 1 || teams[0].Score != 350 || teams[0].Players != 2 {
+        t.Errorf("have '%+v'", teams)
+    }
+}
+
+func TestFanOut(t *testing.T) {
+    mu := sync.Mutex{}
+    running, peak := 0, 0
+    failed, err := fanOut(20, 3, func(i int) error {
+        mu.Lock()
+        running++
+        if running > peak {
+            peak = running
+        }
+        mu.Unlock()
+        time.Sleep(time.Millisecond)
+        mu.Lock()
+        running--
+        mu.Unlock()
+        if i%5 == 0 {
+            return fmt.Errorf("call %d", i)
+        }
+        return nil
+    })
+    if peak > 3 || failed != 4 || err == nil {
+        t.Errorf("have peak '%d' failed '%d' (%v)", peak, failed, err)
+    }
+}
+
+func TestEventTrackerPoll(t *testing.T) {
+    start := time.Unix(1700000000, 0)
+    ev := EventDetails{StartEpoch: EpochFromTime(start), Type: "atlas"}
+    w, _ := testServer(t, map[string]func(*http.Request) interface{}{
+        "/v1/atlas/player/event/score": func(r *http.Request) interface{} {
+            key := r.Header.Get("X-WarDragons-APIKey")
+            if key == "bad" {
+                return http.StatusInternalServerError
+            }
+            return []AtlasEvent{{Score: len(key), PlayerName: key, TeamName: "T", EventDetails: ev}}
+        },
+    })
+    w.Clock = func() time.Time { return start.Add(time.Hour) }
+    et := NewEventTracker(0)
+    err := et.Poll(*w, []string{"a", "bb", "bad", "ccc"}, 2)
+    if err == nil || !strings.HasPrefix(err.Error(), "1 of 4 keys failed") {
+        t.Errorf("have '%v'", err)
+    }
+    players := et.Players(ev.Key())
+    if len(players) != 3 || players[0].Player != "ccc" || !players[0].History[0].At.Equal(start.Add(time.Hour)) {
+        t.Errorf("have '%+v'", players)
+    }
+}
+
+func TestProfileStore(t *testing.T) {
+    start := time.Unix(1700000000, 0)
+    s := NewProfileStore()
+    s.Add(start, Profile{PGID: "1", Name: "a", XP: 100, LifetimeFlames: 10, TopDragons: []Dragon{{ID: "d", AP: 100}}})
+    s.Add(start.Add(48*time.Hour), Profile{PGID: "1", Name: "a", XP: 300, LifetimeFlames: 30, TopDragons: []Dragon{{ID: "d", AP: 150}}, Timestamps: Epochs{LastSeen: EpochFromTime(start.Add(47 * time.Hour))}})
+
+    st, ok := s.Stats("1", start.Add(72*time.Hour))
+    if !ok || st.XPPerDay != 100 || st.FlamesPerDay != 10 || st.DragonsAPChange != 50 || st.InactiveFor != 25*time.Hour {
+        t.Errorf("have '%+v'", st)
+    }
+    if res := s.Inactive(start.Add(72*time.Hour), 24*time.Hour); len(res) != 1 {
+        t.Errorf("have '%+v'", res)
+    }
+    if res := s.DragonProgression("1"); len(res) != 1 || len(res[0].Points) != 2 {
+        t.Errorf("have '%+v'", res)
+    }
+}
+
+func TestDragonCatalog(t *testing.T) {
+    c, err := ReadDragonCatalog(strings.NewReader(`{"dragons": [{"id": "test_dragon", "name": "Test", "rarity": "legendary", "element": "fire", "tier": 3}]}`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    d := Dragon{ID: "test_dragon", AP: 10}
+    if d.Name() != "test_dragon" || d.Rarity() != "" {
+        t.Errorf("have '%s' '%s' from the embedded catalog", d.Name(), d.Rarity())
+    }
+
+    merged := NewDragonCatalog(DragonInfo{ID: "other", Name: "Other", Rarity: RarityCommon})
+    merged.Merge(c)
+    if merged.Len() != 2 || merged.Name(d) != "Test" || merged.Name(Dragon{ID: "unknown"}) != "unknown" {
+        t.Errorf("have '%+v'", merged.List())
+    }
+    if r := merged.Rarity(d); !r.AtLeast(RarityEpic) || r.AtLeast(RarityMythic) {
+        t.Errorf("have '%s'", r)
+    }
+    if DragonRarity("").AtLeast(RarityCommon) {
+        t.Error("unknown rarity is at least common")
+    }
+    if res := fmt.Sprint(d); res != "{10 test_dragon}" {
+        t.Errorf("have '%s' want '%s'", res, "{10 test_dragon}")
+    }
+}
+
+func TestPlayerSummary(t *testing.T) {
+    p := Profile{Name: "a", TotalAP: 1000, TopDragons: []Dragon{{ID: "x", AP: 100}, {ID: "y", AP: 400}, {ID: "z", AP: 300}, {ID: "w", AP: 200}}}
+    s := p.Summary()
+    if s.DragonsAP != 900 || s.AP != 1000 || s.APDiscrepancy != 100 || s.TopDragons[0].ID != "y" {
+        t.Errorf("have '%+v'", s)
+    }
+    p.TotalAP = 0
+    if s := p.Summary(); s.AP != 900 || s.APDiscrepancy != 0 {
+        t.Errorf("have '%+v'", s)
+    }
+}
+
+func TestGetProfiles(t *testing.T) {
+    w, hits := testServer(t, map[string]func(*http.Request) interface{}{
+        "/v1/player/public/my_profile": func(r *http.Request) interface{} {
+            key := r.URL.Query().Get("apikey")
+            if key == "bad" {
+                return http.StatusInternalServerError
+            }
+            return Profile{Name: key, PGID: "pg-" + key, TotalAP: 100 * len(key)}
+        },
+    })
+    keys := []string{"a", "bad", "ccc", "bb"}
+    profiles, err := w.GetProfiles(keys, 2)
+    if err == nil || !strings.HasPrefix(err.Error(), "1 of 4 keys failed") {
+        t.Errorf("have '%v'", err)
+    }
+    if len(profiles) != 4 || profiles[1] != nil || profiles[0].Name != "a" || profiles[3].Name != "bb" {
+        t.Errorf("have '%+v'", profiles)
+    }
+
+    summaries, err := w.GetPlayerSummaries(keys, 0)
+    if err == nil || len(summaries) != 3 || summaries[0].Name != "ccc" || summaries[2].AP != 100 {
+        t.Errorf("have '%+v' (%v)", summaries, err)
+    }
+
+    w.Clock = func() time.Time { return time.Unix(1700000000, 0) }
+    store := NewProfileStore()
+    if err := store.Collect(*w, []string{"a", "bb"}, 1); err != nil {
+        t.Fatal(err)
+    }
+    if res := store.Players(); len(res) != 2 || res[0] != "pg-a" || !store.History("pg-a")[0].At.Equal(time.Unix(1700000000, 0)) {
+        t.Errorf("have '%v'", res)
+    }
+    if hits["/v1/player/public/my_profile"] != 10 {
+        t.Errorf("have '%d' requests want '%d'", hits["/v1/player/public/my_profile"], 10)
+    }
+}
+
+// testServer serves the given JSON responses by URL path and counts the requests per path
+func testServer(t *testing.T, routes map[string]func(r *http.Request) interface{}) (*WDAPI, map[string]int) {
+    t.Helper()
+    mu := sync.Mutex{}
+    hits := make(map[string]int)
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        hits[r.URL.Path]++
+        mu.Unlock()
+        route, ok := routes[r.URL.Path]
+        if !ok {
+            http.NotFound(w, r)
+            return
+        }
+        res := route(r)
+        if code, ok := res.(int); ok {
+            w.WriteHeader(code)
+            return
+        }
+        json.NewEncoder(w).Encode(res)
+    }))
+    t.Cleanup(srv.Close)
+    return New(srv.URL, "v1", "secret", "id", "key"), hits
+}
+
+func testReport(att, def string, ts time.Time, initial, lost int, destroyed float64) Report {
+    return Report{
+        Attacker:         BattlePrim{Name: att, Team: "us", Troops: Ships{Initial: 100, Lost: 10}, GloryWon: 5},
+        Defender:         BattlePrim{Name: def, Team: "them", Troops: Ships{Initial: initial, Lost: lost}},
+        PlaceID:          PlaceID{KingdomID: 1, RegionID: "A0", ContIDX: 0},
+        Timestamp:        PGTSFromTime(ts),
+        PercentDestroyed: destroyed,
+    }
+}
+
+func TestAttackerWon(t *testing.T) {
+    now := time.Unix(1700000000, 0)
+    cases := []struct {
+        r    Report
+        want bool
+    }{
+        {testReport("a", "b", now, 50, 50, 40), true},
+        {testReport("a", "b", now, 50, 20, 40), false},
+        {testReport("a", "b", now, 0, 0, 100), true},
+        {testReport("a", "b", now, 0, 0, 0), true},
+        {testReport("a", "b", now, 50, 20, 100), true},
+    }
+    for i, c := range cases {
+        if res := c.r.AttackerWon(); res != c.want {
+            t.Errorf("%d: have '%v' want '%v'", i, res, c.want)
+        }
+    }
+}
+
+func TestBattleAnalytics(t *testing.T) {
+    start := time.Unix(1700000000, 0).Truncate(time.Hour)
+    reports := []Report{
+        testReport("a", "x", start.Add(10*time.Minute), 50, 50, 100),
+        testReport("a", "x", start.Add(20*time.Minute), 50, 10, 30),
+        testReport("b", "x", start.Add(90*time.Minute), 0, 0, 100),
+    }
+    b := NewBattleAnalytics("us", time.Hour)
+    b.Add(reports[:2]...)
+    b.Add(reports[1:]...)
+
+    if a := b.Players["a"]; a.Attacks.Battles != 2 || a.Attacks.Wins != 1 || a.Attacks.Killed != 60 || a.Attacks.Lost != 20 {
+        t.Errorf("have '%+v'", a.Attacks)
+    }
+    if x := b.Players["x"]; x.Defenses.Battles != 3 || x.Defenses.Wins != 1 {
+        t.Errorf("have '%+v'", x.Defenses)
+    }
+
+    series := b.Opponents["them"].Series()
+    if len(series) != 2 || !series[0].Start.Equal(start) || series[0].Battles != 2 || series[1].Battles != 1 || series[1].Wins != 1 {
+        t.Errorf("have '%+v'", series)
+    }
+    if c := b.Castles["1-A0-0"]; c.Battles != 3 || c.Wins != 2 {
+        t.Errorf("have '%+v'", c.BattleCounts)
+    }
+
+    ranking := b.Ranking(func(p PlayerBattleStats) float64 { return float64(p.Attacks.Wins) })
+    if len(ranking) != 3 || ranking[0].Name != "a" || ranking[1].Name != "b" || ranking[2].Name != "x" {
+        t.Errorf("have '%+v'", ranking)
+    }
+}
+
+func TestPrimarchBreakdown(t *testing.T) {
+    since := time.Unix(1700000000, 0)
+    attack := func(dtype string, level, killed, lost int, at time.Time) Report {
+        r := testReport("a", "x", at, 100, killed, 0)
+        r.Attacker.Troops.Lost = lost
+        r.Defender.Prim = Primarch{Type: dtype, Level: level}
+        r.Attacker.Prim = Primarch{Type: "rusher1", Level: 1}
+        return r
+    }
+    defense := testReport("x", "a", since.Add(time.Minute), 100, 20, 0)
+    defense.Attacker.Team, defense.Defender.Team = "them", "us"
+    defense.Attacker.Troops.Lost = 70
+    defense.Attacker.Prim = Primarch{Type: "taunter2", Level: 12}
+    defense.Defender.Prim = Primarch{Type: "sieger5", Level: 99}
+    other := attack("sieger5", 25, 1000, 1000, since)
+    other.Attacker.Team = "other"
+
+    b := NewPrimarchBreakdown("us", since)
+    b.Add(
+        attack("sieger5", 25, 40, 10, since),
+        attack("sieger5", 30, 60, 20, since),
+        attack("garrison", 10, 100, 30, since),
+        attack("garrison", 10, 100, 50, since),
+        attack("garrison", 20, 100, 100, since),
+        attack("mystery3", 5, 5, 5, since),
+        attack("sieger5", 25, 500, 500, since.Add(-time.Second)),
+        defense,
+        other,
+    )
+
+    gold2 := PrimarchType{Class: ClassSieger, Tier: TierGold2}
+    if res := b.Get(gold2); res.Battles != 2 || res.Killed != 100 || res.Lost != 30 || res.Wins != 0 {
+        t.Errorf("have '%+v' for Gold 2 Siegers", res)
+    }
+    // on defense the group is the attacker's primarch, killed its losses and lost our own
+    if res := b.Get(PrimarchType{Class: ClassTaunter, Tier: TierSilver1}); res.Battles != 1 || res.Killed != 70 || res.Lost != 20 || res.Wins != 1 {
+        t.Errorf("have '%+v' for Silver 1 Taunters", res)
+    }
+    if b.Unknown.Battles != 1 || b.Unknown.Lost != 5 {
+        t.Errorf("have '%+v' unknown", b.Unknown)
+    }
+
+    levels := []string{}
+    for _, g := range b.ByLevel() {
+        levels = append(levels, fmt.Sprintf("%s:%d", g.PrimarchInfo.DType(), g.Battles))
+    }
+    classes := []string{}
+    for _, g := range b.ByClassTier() {
+        classes = append(classes, fmt.Sprintf("%s:%d", g.PrimarchInfo.DType(), g.Battles))
+    }
+    sort.Strings(levels)
+    sort.Strings(classes)
+    if res := strings.Join(levels, " "); res != "garrison:1 garrison:2 sieger5:1 sieger5:1 taunter2:1" {
+        t.Errorf("have '%s'", res)
+    }
+    if res := strings.Join(classes, " "); res != "garrison:3 sieger5:2 taunter2:1" {
+        t.Errorf("have '%s'", res)
+    }
+
+    costs := FortAttackCosts(b)
+    if len(costs) != 2 || costs[10] != 40 || costs[20] != 100 {
+        t.Errorf("have '%v' want '%v'", costs, map[int]int{10: 40, 20: 100})
+    }
+    inv := MergeTroopCounts(&TroopCount{TroopCount: map[string]TC{"t1": {Total: 130}}})
+    if res, ok := inv.SustainableAttacksAt(10, costs); !ok || res != 3 {
+        t.Errorf("have '%d' want '%d'", res, 3)
+    }
+}
+
+func TestBattleAnalyticsOtherTeams(t *testing.T) {
+    r := testReport("o", "p", time.Unix(1700000000, 0), 50, 50, 100)
+    r.Attacker.Team, r.Defender.Team = "other", "third"
+    b := NewBattleAnalytics("us", time.Hour)
+    b.Add(r)
+    if len(b.Opponents) != 0 || len(b.Castles) != 0 || b.Players["o"].Attacks.Wins != 1 {
+        t.Errorf("have opponents '%+v' castles '%+v'", b.Opponents, b.Castles)
+    }
+}
+
+func TestBattleAnalyticsAddAll(t *testing.T) {
+    start := time.Unix(1700000000, 0)
+    w, hits := testServer(t, map[string]func(*http.Request) interface{}{
+        "/v1/atlas/team/battles": func(r *http.Request) interface{} {
+            switch r.URL.Query().Get("cursor") {
+            case "":
+                return Battles{Cursor: "2", More: true, Reports: []Report{
+                    testReport("a", "x", start.Add(3*time.Hour), 50, 50, 100),
+                    testReport("a", "x", start.Add(2*time.Hour), 50, 50, 100),
+                }}
+            case "2":
+                return Battles{Cursor: "3", More: true, Reports: []Report{
+                    testReport("a", "x", start.Add(2*time.Hour), 50, 50, 100),
+                    testReport("b", "x", start.Add(-time.Hour), 50, 50, 100),
+                }}
+            }
+            return Battles{Reports: []Report{testReport("c", "x", start.Add(-2*time.Hour), 50, 50, 100)}}
+        },
+    })
+    b := NewBattleAnalytics("us", time.Hour)
+    if err := b.AddAll(*w, "key", start); err != nil {
+        t.Fatal(err)
+    }
+    if len(b.Players) != 2 || b.Players["a"].Attacks.Battles != 2 || hits["/v1/atlas/team/battles"] != 2 {
+        t.Errorf("have '%+v' after %d requests", b.Players, hits["/v1/atlas/team/battles"])
+    }
+
+    b = NewBattleAnalytics("us", time.Hour)
+    if err := b.AddAll(*w, "key", time.Time{}); err != nil {
+        t.Fatal(err)
+    }
+    if len(b.Players) != 4 {
+        t.Errorf("have '%+v'", b.Players)
+    }
+}
+
+// decodeGeoJSON marshals fc and decodes it again to check the wire format
+func decodeGeoJSON(t *testing.T, fc *FeatureCollection) map[string]interface{} {
+    t.Helper()
+    out, err := json.Marshal(fc)
+    if err != nil {
+        t.Fatal(err)
+    }
+    res := map[string]interface{}{}
+    if err := json.Unmarshal(out, &res); err != nil {
+        t.Fatal(err)
+    }
+    if res["type"] != "FeatureCollection" {
+        t.Errorf("have '%v' want '%s'", res["type"], "FeatureCollection")
+    }
+    return res
+}
+
+func geoFeatures(fc map[string]interface{}) []map[string]interface{} {
+    ret := []map[string]interface{}{}
+    for _, f := range fc["features"].([]interface{}) {
+        ret = append(ret, f.(map[string]interface{}))
+    }
+    return ret
+}
+
+func TestGeoJSON(t *testing.T) {
+    m := &CastlesMacro{Castles: map[string]Castle{
+        "1-A0-0": {OwnerTeam: "A", Level: 10, Coords: CoordsFromMap(0, 0)},
+        "1-A0-1": {OwnerTeam: "B", Level: 5, Coords: CoordsFromMap(60, 0)},
+        "1-B2-3": {OwnerTeam: "A", Level: 2, Coords: CoordsFromMap(10, 20)},
+    }}
+    alliances := &Alliances{Alliances: []map[string][]string{{"X": {"A", "B"}}}}
+    owned := time.Unix(1700000000, 0)
+    info := map[string]CastleInfo{
+        "1-A0-1": {
+            PlaceID:         PlaceID{KingdomID: 1, RegionID: "A0", ContIDX: 1},
+            OwnerTeam:       "C",
+            OwnerAlliance:   "Y",
+            Level:           7,
+            CustomName:      "Keep",
+            OwnedSinceEpoch: EpochFromTime(owned),
+        },
+    }
+
+    castles := geoFeatures(decodeGeoJSON(t, CastlesGeoJSON(m, info, alliances)))
+    if len(castles) != 3 {
+        t.Fatalf("have '%d' features want '%d'", len(castles), 3)
+    }
+    for _, f := range castles {
+        g := f["geometry"].(map[string]interface{})
+        if f["type"] != "Feature" || g["type"] != "Point" || len(g["coordinates"].([]interface{})) != 2 {
+            t.Errorf("have '%+v'", f)
+        }
+    }
+    if p := castles[2]["properties"].(map[string]interface{}); castles[2]["id"] != "1-B2-3" || p["kingdom"] != 1.0 || p["region"] != "B2" || p["index"] != 3.0 || p["alliance"] != "X" || p["owned_since"] != nil {
+        t.Errorf("have '%+v'", castles[2])
+    }
+    want := map[string]interface{}{
+        "owner_team":  "C",
+        "alliance":    "Y",
+        "level":       7.0,
+        "custom_name": "Keep",
+        "owned_since": "2023-11-14T22:13:20Z",
+        "kingdom":     1.0,
+        "region":      "A0",
+        "index":       1.0,
+    }
+    p := castles[1]["properties"].(map[string]interface{})
+    for k, v := range want {
+        if p[k] != v {
+            t.Errorf("have '%s' '%v' want '%v'", k, p[k], v)
+        }
+    }
+    if c := castles[1]["geometry"].(map[string]interface{})["coordinates"].([]interface{}); c[0] != 60.0 || c[1] != 0.0 {
+        t.Errorf("have '%v' want '%v'", c, []float64{60, 0})
+    }
+
+    teams := geoFeatures(decodeGeoJSON(t, TeamsGeoJSON(m)))
+    if len(teams) != 2 || teams[0]["id"] != "A" {
+        t.Fatalf("have '%+v'", teams)
+    }
+    g := teams[0]["geometry"].(map[string]interface{})
+    if g["type"] != "MultiPoint" || len(g["coordinates"].([]interface{})) != 2 || teams[0]["properties"].(map[string]interface{})["total_levels"] != 12.0 {
+        t.Errorf("have '%+v'", teams[0])
+    }
+
+    all := geoFeatures(decodeGeoJSON(t, AlliancesGeoJSON(m, alliances)))
+    if len(all) != 1 || all[0]["properties"].(map[string]interface{})["alliance"] != "X" || all[0]["properties"].(map[string]interface{})["castles"] != 3.0 {
+        t.Errorf("have '%+v'", all)
+    }
+
+    terr := geoFeatures(decodeGeoJSON(t, TerritoryGeoJSON(ComputeTerritory(m, TerritoryOptions{CellSize: 5, Radius: 30}))))
+    if len(terr) != 2 {
+        t.Fatalf("have '%d' features want '%d'", len(terr), 2)
+    }
+    for _, f := range terr {
+        g := f["geometry"].(map[string]interface{})
+        if g["type"] != "MultiPolygon" {
+            t.Errorf("have '%v' want '%s'", g["type"], "MultiPolygon")
+        }
+        for _, poly := range g["coordinates"].([]interface{}) {
+            for _, r := range poly.([]interface{}) {
+                ring := r.([]interface{})
+                first, last := ring[0].([]interface{}), ring[len(ring)-1].([]interface{})
+                if len(ring) < 4 || first[0] != last[0] || first[1] != last[1] {
+                    t.Errorf("have open ring '%v'", ring)
+                }
+            }
+        }
+    }
+}
+
+func TestFetchWorld(t *testing.T) {
+    w, hits := testServer(t, map[string]func(*http.Request) interface{}{
+        "/v1/atlas/castles/metadata/macro": func(r *http.Request) interface{} {
+            q := r.URL.Query()
+            switch q.Get("realm_name") + "/" + q.Get("k_id") {
+            case "a/1":
+                return CastlesMacro{Castles: map[string]Castle{"A0-0": {OwnerTeam: "red", Level: 1}, "A0-1": {OwnerTeam: "blue", Level: 1}}}
+            case "b/1":
+                return CastlesMacro{Castles: map[string]Castle{"A0-0": {OwnerTeam: "green", Level: 2}}}
+            case "b/2":
+                return CastlesMacro{Castles: map[string]Castle{"A0-0": {OwnerTeam: "red", Level: 3}}}
+            }
+            return http.StatusInternalServerError
+        },
+        "/v1/atlas/teams/metadata/macro": func(r *http.Request) interface{} {
+            q := r.URL.Query()
+            if q.Get("realm_name") == "b" && q.Get("k_id") == "1" {
+                return TeamsMacro{Teams: map[string]TeamMacro{"green": {}, "red": {Elo: 1000}}}
+            }
+            return TeamsMacro{Teams: map[string]TeamMacro{}}
+        },
+    })
+    r := NewRegistry()
+    r.Add("a", 1, 3)
+    r.Add("b", 1, 2)
+    world, err := w.FetchRegistry(r, 2)
+
+    ferr, ok := err.(FetchErrors)
+    if !ok || len(ferr) != 1 || ferr[Kingdom{ID: 3, Realm: "a"}] == nil {
+        t.Fatalf("have '%v' want one failed kingdom", err)
+    }
+    if hits["/v1/atlas/castles/metadata/macro"] != 4 || len(world.CastlesMacros) != 3 {
+        t.Errorf("have '%d' requests and '%d' kingdoms", hits["/v1/atlas/castles/metadata/macro"], len(world.CastlesMacros))
+    }
+
+    if len(world.Castles) != 4 {
+        t.Errorf("have '%d' castles want '%d'", len(world.Castles), 4)
+    }
+    if c := world.Castles[WorldCastleID{Realm: "a", KRIDX: "1-A0-0"}]; c.OwnerTeam != "red" {
+        t.Errorf("have '%+v' want owner '%s'", c, "red")
+    }
+    if c := world.Castles[WorldCastleID{Realm: "b", KRIDX: "1-A0-0"}]; c.OwnerTeam != "green" {
+        t.Errorf("have '%+v' want owner '%s'", c, "green")
+    }
+
+    want := []WorldCastleID{{Realm: "a", KRIDX: "1-A0-0"}, {Realm: "b", KRIDX: "2-A0-0"}}
+    if res := world.TeamCastles("red"); fmt.Sprint(res) != fmt.Sprint(want) {
+        t.Errorf("have '%v' want '%v'", res, want)
+    }
+    kingdoms := []Kingdom{{ID: 1, Realm: "a"}, {ID: 1, Realm: "b"}, {ID: 2, Realm: "b"}}
+    if res := world.TeamKingdoms("red"); fmt.Sprint(res) != fmt.Sprint(kingdoms) {
+        t.Errorf("have '%v' want '%v'", res, kingdoms)
+    }
+    if res := world.Team("red"); len(res) != 1 || res[Kingdom{ID: 1, Realm: "b"}].Elo != 1000 {
+        t.Errorf("have '%+v'", res)
+    }
+}
+
+func TestStandings(t *testing.T) {
+    silver1 := League{LeagueID: "silver", SubleagueID: "a", DivisionID: 1}
+    silver2 := League{LeagueID: "silver", SubleagueID: "a", DivisionID: 2}
+    gold5 := League{LeagueID: "gold", SubleagueID: "a", DivisionID: 5}
+    prev := NewStandings(&TeamsMacro{Teams: map[string]TeamMacro{
+        "A": {LeagueInfo: silver1, Elo: 1500},
+        "B": {LeagueInfo: silver1, Elo: 1400},
+        "C": {LeagueInfo: silver1, Elo: 1400, Influence: 10},
+        "D": {LeagueInfo: silver2, Elo: 1000},
+        "E": {LeagueInfo: League{LeagueID: "mystery"}, Elo: 900},
+        "F": {LeagueInfo: silver2, Elo: 800},
+    }}, nil)
+
+    d, ok := prev.Division("B")
+    if !ok || d.League != silver1 || len(d.Teams) != 3 {
+        t.Fatalf("have '%+v'", d)
+    }
+    order := []string{}
+    for _, v := range d.Teams {
+        order = append(order, fmt.Sprintf("%d:%s", v.Position, v.Team))
+    }
+    if res := strings.Join(order, " "); res != "1:A 2:C 3:B" {
+        t.Errorf("have '%s' want '%s'", res, "1:A 2:C 3:B")
+    }
+    if _, ok := prev.Division("Z"); ok {
+        t.Error("have a division for an unknown team")
+    }
+    if res := prev.List(); len(res) != 3 || res[0].League != silver1 || res[1].League != silver2 || res[2].League.LeagueID != "mystery" {
+        t.Errorf("have '%+v'", res)
+    }
+
+    next := NewStandings(&TeamsMacro{Teams: map[string]TeamMacro{
+        "A": {LeagueInfo: gold5},
+        "B": {LeagueInfo: silver2},
+        "C": {LeagueInfo: silver1},
+        "D": {LeagueInfo: silver1},
+        "E": {LeagueInfo: silver2},
+    }}, nil)
+    want := []LeagueMove{
+        {Team: "A", From: silver1, To: gold5, Direction: 1},
+        {Team: "B", From: silver1, To: silver2, Direction: -1},
+        {Team: "D", From: silver2, To: silver1, Direction: 1},
+        {Team: "E", From: League{LeagueID: "mystery"}, To: silver2, Direction: 0},
+    }
+    if res := next.Moves(prev); fmt.Sprint(res) != fmt.Sprint(want) {
+        t.Errorf("have '%+v' want '%+v'", res, want)
+    }
+
+    reversed := LeagueOrder{Leagues: []string{"silver", "gold"}, HigherDivisionsFirst: true}
+    next = NewStandings(&TeamsMacro{Teams: map[string]TeamMacro{"A": {LeagueInfo: gold5}, "B": {LeagueInfo: silver2}}}, &reversed)
+    if res := next.Moves(prev); len(res) != 2 || res[0].Direction != -1 || res[1].Direction != 1 {
+        t.Errorf("have '%+v'", res)
+    }
+}
+
+func TestCheckShields(t *testing.T) {
+    since := time.Date(2023, 11, 14, 22, 13, 0, 0, time.UTC)
+    fort := func(shielded bool, lost float64, guards, day int) CastleInfo {
+        return CastleInfo{OwnerTeam: "us", Infrastructure: Infra{Fort: Fort{
+            ShieldTurnedOn:   shielded,
+            ShieldTroopsLost: lost,
+            ShieldTimeTS:     EpochFromTime(since),
+            GuardsHiredToday: guards,
+            DayIDX:           day,
+        }}}
+    }
+    castles := map[string]CastleInfo{
+        "1-A0-0": fort(true, 500, 1, 7),
+        "1-A0-1": fort(true, 850, 3, 7),
+        "1-A0-2": fort(true, 1200, 0, 8),
+        "1-A0-3": fort(false, 0, 2, 8),
+        "1-A0-4": {OwnerTeam: "them"},
+    }
+    opts := ShieldOptions{Team: "us", TroopBudget: 1000, GuardQuota: 3}
+    r := CheckShields(castles, opts)
+
+    if len(r.Statuses) != 4 {
+        t.Fatalf("have '%d' statuses want '%d'", len(r.Statuses), 4)
+    }
+    if s := r.Statuses[1]; !s.BudgetTracked || s.Budget != 150 || s.GuardsLeft != 0 || s.BudgetUsed(opts) != 0.85 {
+        t.Errorf("have '%+v'", s)
+    }
+    if s := r.Statuses[2]; s.Budget != 0 || s.GuardsLeft != 3 {
+        t.Errorf("have '%+v'", s)
+    }
+    alerts := []string{}
+    for _, a := range r.Alerts {
+        alerts = append(alerts, a.Castle+" "+string(a.Level))
+    }
+    want := "1-A0-2 critical, 1-A0-1 warning, 1-A0-1 info, 1-A0-3 info"
+    if res := strings.Join(alerts, ", "); res != want {
+        t.Errorf("have '%s' want '%s'", res, want)
+    }
+    if r.GuardsPerDay[7] != 4 || r.GuardsPerDay[8] != 2 {
+        t.Errorf("have '%v'", r.GuardsPerDay)
+    }
+
+    b := strings.Builder{}
+    if err := r.WriteTable(&b); err != nil {
+        t.Fatal(err)
+    }
+    lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+    if len(lines) != 5 || strings.Join(strings.Fields(lines[2]), " ") != "1-A0-1 up 2023-11-14 22:13 850 150 3 7" {
+        t.Errorf("have\n%s", b.String())
+    }
+
+    b.Reset()
+    untracked := CheckShields(castles, ShieldOptions{Team: "us"})
+    if err := untracked.WriteTable(&b); err != nil {
+        t.Fatal(err)
+    }
+    lines = strings.Split(strings.TrimSpace(b.String()), "\n")
+    if res := strings.Join(strings.Fields(lines[1]), " "); res != "1-A0-0 up 2023-11-14 22:13 500 - 1 7" {
+        t.Errorf("have '%s'", res)
+    }
+    if len(untracked.Alerts) != 1 || untracked.Alerts[0].Castle != "1-A0-3" {
+        t.Errorf("have '%+v'", untracked.Alerts)
+    }
+}
@@ -0,0 +1,224 @@
+package wdapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Kingdom struct {
+	ID    int
+	Realm string
+}
+
+func (k Kingdom) String() string {
+	return fmt.Sprintf("%s/%d", k.Realm, k.ID)
+}
+
+type Realm struct {
+	Name     string
+	Kingdoms []int
+}
+
+// Registry keeps track of the kingdoms to fetch, grouped by realm. It is safe for concurrent use
+type Registry struct {
+	mu     sync.RWMutex
+	realms map[string]map[int]struct{}
+}
+
+func NewRegistry() *Registry {
+	return &Registry{realms: make(map[string]map[int]struct{})}
+}
+
+func (r *Registry) Add(realm string, kingdomIDs ...int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.realms[realm]; !ok {
+		r.realms[realm] = make(map[int]struct{})
+	}
+	for _, v := range kingdomIDs {
+		r.realms[realm][v] = struct{}{}
+	}
+}
+
+func (r *Registry) Remove(realm string, kingdomID int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.realms[realm], kingdomID)
+	if len(r.realms[realm]) == 0 {
+		delete(r.realms, realm)
+	}
+}
+
+// Realms returns the registered realms sorted by name, each with its kingdoms in ascending order
+func (r *Registry) Realms() []Realm {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ret := make([]Realm, 0, len(r.realms))
+	for name, ks := range r.realms {
+		realm := Realm{Name: name}
+		for k := range ks {
+			realm.Kingdoms = append(realm.Kingdoms, k)
+		}
+		sort.Ints(realm.Kingdoms)
+		ret = append(ret, realm)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// Kingdoms returns all registered kingdoms sorted by realm and ID
+func (r *Registry) Kingdoms() []Kingdom {
+	ret := []Kingdom{}
+	for _, realm := range r.Realms() {
+		for _, k := range realm.Kingdoms {
+			ret = append(ret, Kingdom{ID: k, Realm: realm.Name})
+		}
+	}
+	return ret
+}
+
+// FetchErrors holds the errors of the kingdoms that could not be fetched
+type FetchErrors map[Kingdom]error
+
+func (f FetchErrors) Error() string {
+	ks := make([]Kingdom, 0, len(f))
+	for k := range f {
+		ks = append(ks, k)
+	}
+	sortKingdoms(ks)
+	s := strings.Builder{}
+	for i, k := range ks {
+		if i > 0 {
+			s.WriteString("; ")
+		}
+		s.WriteString(fmt.Sprintf("%s: %s", k, f[k]))
+	}
+	return s.String()
+}
+
+func sortKingdoms(ks []Kingdom) {
+	sort.Slice(ks, func(i, j int) bool {
+		if ks[i].Realm != ks[j].Realm {
+			return ks[i].Realm < ks[j].Realm
+		}
+		return ks[i].ID < ks[j].ID
+	})
+}
+
+// WorldCastleID identifies a castle across realms, the same KRIDX can exist in several realms
+type WorldCastleID struct {
+	Realm string
+	KRIDX string
+}
+
+func (c WorldCastleID) String() string {
+	return c.Realm + "/" + c.KRIDX
+}
+
+// World is the combined view of several kingdoms, possibly from different realms
+type World struct {
+	Castles       map[WorldCastleID]Castle
+	CastlesMacros map[Kingdom]*CastlesMacro
+	TeamsMacros   map[Kingdom]*TeamsMacro
+}
+
+// FetchWorld fetches the castles and teams macros of all kingdoms with at most concurrency requests in flight.
+// concurrency defaults to 4. The returned World contains everything that could be fetched,
+// the error is a FetchErrors if any kingdom failed
+func (w WDAPI) FetchWorld(kingdoms []Kingdom, concurrency int) (*World, error) {
+	world := &World{
+		Castles:       make(map[WorldCastleID]Castle),
+		CastlesMacros: make(map[Kingdom]*CastlesMacro),
+		TeamsMacros:   make(map[Kingdom]*TeamsMacro),
+	}
+	errs := FetchErrors{}
+
+	mu := sync.Mutex{}
+	fanOut(len(kingdoms), concurrency, func(i int) error {
+		k := kingdoms[i]
+		castles, err := w.GetCastlesMacro(k.ID, k.Realm)
+		var teams *TeamsMacro
+		if err == nil {
+			teams, err = w.GetTeamsMetadataMacro(k.ID, k.Realm)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs[k] = err
+			return err
+		}
+		world.CastlesMacros[k] = castles
+		world.TeamsMacros[k] = teams
+		return nil
+	})
+
+	for k, m := range world.CastlesMacros {
+		for id, c := range m.Castles {
+			world.Castles[WorldCastleID{Realm: k.Realm, KRIDX: id}] = c
+		}
+	}
+	if len(errs) > 0 {
+		return world, errs
+	}
+	return world, nil
+}
+
+// FetchRegistry is FetchWorld for all kingdoms of the registry
+func (w WDAPI) FetchRegistry(r *Registry, concurrency int) (*World, error) {
+	return w.FetchWorld(r.Kingdoms(), concurrency)
+}
+
+// TeamKingdoms returns the kingdoms in which the team owns castles or is listed in the teams macro
+func (w *World) TeamKingdoms(team string) []Kingdom {
+	found := make(map[Kingdom]struct{})
+	for k, m := range w.CastlesMacros {
+		for _, c := range m.Castles {
+			if c.OwnerTeam == team {
+				found[k] = struct{}{}
+				break
+			}
+		}
+	}
+	for k, m := range w.TeamsMacros {
+		if _, ok := m.Teams[team]; ok {
+			found[k] = struct{}{}
+		}
+	}
+	ret := make([]Kingdom, 0, len(found))
+	for k := range found {
+		ret = append(ret, k)
+	}
+	sortKingdoms(ret)
+	return ret
+}
+
+// TeamCastles returns every castle the team owns across all kingdoms, sorted by realm and KRIDX
+func (w *World) TeamCastles(team string) []WorldCastleID {
+	ret := []WorldCastleID{}
+	for id, c := range w.Castles {
+		if c.OwnerTeam == team {
+			ret = append(ret, id)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Realm != ret[j].Realm {
+			return ret[i].Realm < ret[j].Realm
+		}
+		return ret[i].KRIDX < ret[j].KRIDX
+	})
+	return ret
+}
+
+// Team returns the team's macro entry in every kingdom it is listed in
+func (w *World) Team(team string) map[Kingdom]TeamMacro {
+	ret := make(map[Kingdom]TeamMacro)
+	for k, m := range w.TeamsMacros {
+		if v, ok := m.Teams[team]; ok {
+			ret[k] = v
+		}
+	}
+	return ret
+}